@@ -24,6 +24,18 @@ const _ = grpc.SupportPackageIsVersion7
 type BooksClient interface {
 	CreateBook(ctx context.Context, in *CreateBookRequest, opts ...grpc.CallOption) (*CreateBookResponse, error)
 	ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error)
+	// GetBook returns the book with the ID. IDs are matched exactly, including case.
+	GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*GetBookResponse, error)
+	BatchCreateBooks(ctx context.Context, in *BatchCreateBooksRequest, opts ...grpc.CallOption) (*BatchCreateBooksResponse, error)
+	// BatchGetBooks returns the books with the IDs, which are matched exactly, including
+	// case, as in GetBook.
+	BatchGetBooks(ctx context.Context, in *BatchGetBooksRequest, opts ...grpc.CallOption) (*BatchGetBooksResponse, error)
+	// ImportBooks imports a stream of records, committing them in chunks. The server
+	// responds with the progress of the import when the stream starts and after each
+	// chunk, and with a summary once the client has finished sending. Both sides stream,
+	// rather than only the client, so that progress reaches the client while it sends.
+	ImportBooks(ctx context.Context, opts ...grpc.CallOption) (Books_ImportBooksClient, error)
+	WatchBooks(ctx context.Context, in *WatchBooksRequest, opts ...grpc.CallOption) (Books_WatchBooksClient, error)
 }
 
 type booksClient struct {
@@ -52,12 +64,114 @@ func (c *booksClient) ListBooks(ctx context.Context, in *ListBooksRequest, opts
 	return out, nil
 }
 
+func (c *booksClient) GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*GetBookResponse, error) {
+	out := new(GetBookResponse)
+	err := c.cc.Invoke(ctx, "/Books/GetBook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *booksClient) BatchCreateBooks(ctx context.Context, in *BatchCreateBooksRequest, opts ...grpc.CallOption) (*BatchCreateBooksResponse, error) {
+	out := new(BatchCreateBooksResponse)
+	err := c.cc.Invoke(ctx, "/Books/BatchCreateBooks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *booksClient) BatchGetBooks(ctx context.Context, in *BatchGetBooksRequest, opts ...grpc.CallOption) (*BatchGetBooksResponse, error) {
+	out := new(BatchGetBooksResponse)
+	err := c.cc.Invoke(ctx, "/Books/BatchGetBooks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *booksClient) ImportBooks(ctx context.Context, opts ...grpc.CallOption) (Books_ImportBooksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Books_ServiceDesc.Streams[0], "/Books/ImportBooks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &booksImportBooksClient{stream}
+	return x, nil
+}
+
+type Books_ImportBooksClient interface {
+	Send(*ImportBooksRequest) error
+	Recv() (*ImportBooksResponse, error)
+	grpc.ClientStream
+}
+
+type booksImportBooksClient struct {
+	grpc.ClientStream
+}
+
+func (x *booksImportBooksClient) Send(m *ImportBooksRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *booksImportBooksClient) Recv() (*ImportBooksResponse, error) {
+	m := new(ImportBooksResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *booksClient) WatchBooks(ctx context.Context, in *WatchBooksRequest, opts ...grpc.CallOption) (Books_WatchBooksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Books_ServiceDesc.Streams[1], "/Books/WatchBooks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &booksWatchBooksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Books_WatchBooksClient interface {
+	Recv() (*WatchBooksResponse, error)
+	grpc.ClientStream
+}
+
+type booksWatchBooksClient struct {
+	grpc.ClientStream
+}
+
+func (x *booksWatchBooksClient) Recv() (*WatchBooksResponse, error) {
+	m := new(WatchBooksResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // BooksServer is the server API for Books service.
 // All implementations must embed UnimplementedBooksServer
 // for forward compatibility
 type BooksServer interface {
 	CreateBook(context.Context, *CreateBookRequest) (*CreateBookResponse, error)
 	ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error)
+	// GetBook returns the book with the ID. IDs are matched exactly, including case.
+	GetBook(context.Context, *GetBookRequest) (*GetBookResponse, error)
+	BatchCreateBooks(context.Context, *BatchCreateBooksRequest) (*BatchCreateBooksResponse, error)
+	// BatchGetBooks returns the books with the IDs, which are matched exactly, including
+	// case, as in GetBook.
+	BatchGetBooks(context.Context, *BatchGetBooksRequest) (*BatchGetBooksResponse, error)
+	// ImportBooks imports a stream of records, committing them in chunks. The server
+	// responds with the progress of the import when the stream starts and after each
+	// chunk, and with a summary once the client has finished sending. Both sides stream,
+	// rather than only the client, so that progress reaches the client while it sends.
+	ImportBooks(Books_ImportBooksServer) error
+	WatchBooks(*WatchBooksRequest, Books_WatchBooksServer) error
 	mustEmbedUnimplementedBooksServer()
 }
 
@@ -71,6 +185,21 @@ func (UnimplementedBooksServer) CreateBook(context.Context, *CreateBookRequest)
 func (UnimplementedBooksServer) ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListBooks not implemented")
 }
+func (UnimplementedBooksServer) GetBook(context.Context, *GetBookRequest) (*GetBookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBook not implemented")
+}
+func (UnimplementedBooksServer) BatchCreateBooks(context.Context, *BatchCreateBooksRequest) (*BatchCreateBooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchCreateBooks not implemented")
+}
+func (UnimplementedBooksServer) BatchGetBooks(context.Context, *BatchGetBooksRequest) (*BatchGetBooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetBooks not implemented")
+}
+func (UnimplementedBooksServer) ImportBooks(Books_ImportBooksServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportBooks not implemented")
+}
+func (UnimplementedBooksServer) WatchBooks(*WatchBooksRequest, Books_WatchBooksServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchBooks not implemented")
+}
 func (UnimplementedBooksServer) mustEmbedUnimplementedBooksServer() {}
 
 // UnsafeBooksServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +249,107 @@ func _Books_ListBooks_Handler(srv interface{}, ctx context.Context, dec func(int
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Books_GetBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BooksServer).GetBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Books/GetBook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BooksServer).GetBook(ctx, req.(*GetBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Books_BatchCreateBooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCreateBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BooksServer).BatchCreateBooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Books/BatchCreateBooks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BooksServer).BatchCreateBooks(ctx, req.(*BatchCreateBooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Books_BatchGetBooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BooksServer).BatchGetBooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Books/BatchGetBooks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BooksServer).BatchGetBooks(ctx, req.(*BatchGetBooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Books_ImportBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BooksServer).ImportBooks(&booksImportBooksServer{stream})
+}
+
+type Books_ImportBooksServer interface {
+	Send(*ImportBooksResponse) error
+	Recv() (*ImportBooksRequest, error)
+	grpc.ServerStream
+}
+
+type booksImportBooksServer struct {
+	grpc.ServerStream
+}
+
+func (x *booksImportBooksServer) Send(m *ImportBooksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *booksImportBooksServer) Recv() (*ImportBooksRequest, error) {
+	m := new(ImportBooksRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Books_WatchBooks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchBooksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BooksServer).WatchBooks(m, &booksWatchBooksServer{stream})
+}
+
+type Books_WatchBooksServer interface {
+	Send(*WatchBooksResponse) error
+	grpc.ServerStream
+}
+
+type booksWatchBooksServer struct {
+	grpc.ServerStream
+}
+
+func (x *booksWatchBooksServer) Send(m *WatchBooksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Books_ServiceDesc is the grpc.ServiceDesc for Books service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,7 +365,31 @@ var Books_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListBooks",
 			Handler:    _Books_ListBooks_Handler,
 		},
+		{
+			MethodName: "GetBook",
+			Handler:    _Books_GetBook_Handler,
+		},
+		{
+			MethodName: "BatchCreateBooks",
+			Handler:    _Books_BatchCreateBooks_Handler,
+		},
+		{
+			MethodName: "BatchGetBooks",
+			Handler:    _Books_BatchGetBooks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportBooks",
+			Handler:       _Books_ImportBooks_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchBooks",
+			Handler:       _Books_WatchBooks_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "books/books.proto",
 }
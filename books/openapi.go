@@ -0,0 +1,9 @@
+package books
+
+import _ "embed"
+
+// OpenAPIYAML is the OpenAPI v3 document for the REST/JSON mapping of the Books service,
+// generated from books.proto by protoc-gen-openapi and cmd/openapi_constraints.
+//
+//go:embed openapi.yaml
+var OpenAPIYAML []byte
@@ -2,21 +2,51 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
 	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
 	"github.com/celestebrant/library-of-books/internal/services/booksclient"
+	"github.com/celestebrant/library-of-books/internal/tracing"
 	"github.com/oklog/ulid/v2"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// main creates a book with a random title and author. It exits with status 1 if that
+// fails, after run has flushed the trace of the failed call.
 func main() {
-	client, conn := booksclient.MustNewBooksClient("127.0.0.1:8089")
+	if err := run(); err != nil {
+		logging.Fatal("client failed", slog.Any("error", err))
+	}
+}
+
+func run() error {
+	conf, err := config.Load(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := logging.SetDefault(conf.Log); err != nil {
+		return fmt.Errorf("failed to set up logging: %w", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	client, conn := booksclient.MustNewBooksClient(conf.Client)
 	defer conn.Close()
 
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Client.RequestTimeout)
+	defer cancel()
+
 	res, err := client.CreateBook(
-		context.Background(),
+		ctx,
 		&books.CreateBookRequest{
 			Book: &books.Book{
 				Id:           ulid.Make().String(),
@@ -28,7 +58,8 @@ func main() {
 		},
 	)
 	if err != nil {
-		log.Fatalf("failed to call CreateBook: %v", err)
+		return fmt.Errorf("failed to call CreateBook: %w", err)
 	}
-	log.Printf("created book via CreateBook: %v", res)
+	slog.Info("created book via CreateBook", slog.Any("book", res.Book))
+	return nil
 }
@@ -3,23 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 	"time"
 
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
 	storage "github.com/celestebrant/library-of-books/storage"
 	"github.com/oklog/ulid/v2"
 )
 
 func main() {
-	dbConnection, err := storage.NewMysqlStorage(storage.MysqlConfig{
-		Username: "user1",
-		Password: "password1",
-		DBName:   "library",
-		Port:     3306,
-		Host:     "localhost", // this code will execute in machine, not container
-	})
+	conf, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("failed to load config", slog.Any("error", err))
+	}
+	if err := logging.SetDefault(conf.Log); err != nil {
+		logging.Fatal("failed to set up logging", slog.Any("error", err))
+	}
+
+	dbConnection, err := storage.NewMysqlStorage(conf.MySQL)
+	if err != nil {
+		logging.Fatal("failed to connect to MySQL", slog.Any("error", err))
 	}
 
 	author, title := "author1", "title1"
@@ -30,17 +35,17 @@ func main() {
 		CreationTime: time.Now().UTC(),
 	}
 	if err = dbConnection.CreateBook(context.Background(), book); err != nil {
-		log.Fatalf(`error encountered during CreateBook SQL operation: %v`, err)
+		logging.Fatal("error encountered during CreateBook SQL operation", slog.Any("error", err))
 	}
 
-	log.Printf(`inserted record into "books" table: %v`, *book)
+	slog.Info(`inserted record into "books" table`, slog.Any("book", *book))
 
 	listBooksRes, err := dbConnection.ListBooks(context.Background(), author, title, 10, "")
 	if err != nil {
-		log.Fatalf(`error encountered during ListBooks SQL operation: %v`, err)
+		logging.Fatal("error encountered during ListBooks SQL operation", slog.Any("error", err))
 	}
 
-	log.Printf(`fetched %d records via ListBooks from "books" table:`, len(listBooksRes.Books))
+	slog.Info(`fetched records via ListBooks from "books" table`, slog.Int("count", len(listBooksRes.Books)))
 	if len(listBooksRes.Books) > 0 {
 		for _, book := range listBooksRes.Books {
 			fmt.Printf("- %v\n", book)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/openapi"
+)
+
+// main rewrites the OpenAPI document generated from books.proto, given as the only
+// argument, with the constraints of request fields applied to their query and path
+// parameters. It is run by the Makefile after protoc.
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: openapi_constraints books/openapi.yaml")
+		os.Exit(2)
+	}
+	path := os.Args[1]
+
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		logging.Fatal("failed to read OpenAPI document", slog.Any("error", err))
+	}
+	doc, err = openapi.ApplyParameterConstraints(doc, books.File_books_books_proto)
+	if err != nil {
+		logging.Fatal("failed to apply parameter constraints", slog.Any("error", err))
+	}
+	if err := os.WriteFile(path, doc, 0o644); err != nil {
+		logging.Fatal("failed to write OpenAPI document", slog.Any("error", err))
+	}
+}
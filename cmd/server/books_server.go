@@ -1,45 +1,99 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
 
-	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
 	"github.com/celestebrant/library-of-books/internal/services/booksservice"
-	"github.com/celestebrant/library-of-books/storage"
-	"google.golang.org/grpc"
+	"github.com/celestebrant/library-of-books/internal/tracing"
 )
 
-const address string = "127.0.0.1:8089"
-
+// main runs the books server until SIGINT or SIGTERM, then drains in-flight requests.
+// It exits with status 0 after a clean shutdown and 1 if serving failed or the drain
+// timeout was exceeded.
 func main() {
+	conf, err := config.Load(os.Args[1:])
+	if err != nil {
+		logging.Fatal("failed to load config", slog.Any("error", err))
+	}
+	if err := logging.SetDefault(conf.Log); err != nil {
+		logging.Fatal("failed to set up logging", slog.Any("error", err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.Tracing)
+	if err != nil {
+		logging.Fatal("failed to set up tracing", slog.Any("error", err))
+	}
+
 	// Create a network listener
-	lis, err := net.Listen("tcp", address)
+	lis, err := net.Listen("tcp", conf.Server.Address)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		logging.Fatal("failed to listen", slog.Any("error", err))
 	}
 
-	// Create a new database connection that the books server can use to write to the db
-	dbConnection, err := storage.NewMysqlStorage(storage.MysqlConfig{
-		Username: "user1",
-		Password: "password1",
-		DBName:   "library",
-		Port:     3306,
-		Host:     "localhost", // this code will execute in machine, not container
-	})
+	server, err := booksservice.NewServer(conf)
 	if err != nil {
-		log.Fatal(err)
+		logging.Fatal("failed to create server", slog.Any("error", err))
 	}
+	slog.Info("gRPC server listening", slog.String("address", conf.Server.Address))
 
-	// Create a new gRPC server registered with booksServer
-	grpcServer := grpc.NewServer()
-	books.RegisterBooksServer(grpcServer, &booksservice.BooksServer{
-		MysqlStorage: &dbConnection,
-	})
-	log.Printf("gRPC server listening on %s", address)
+	// Connect the new server to the network listener. From here on, failures to listen
+	// or serve shut the server down before exiting, like a signal.
+	serveErr := make(chan error, 3)
+	go func() {
+		serveErr <- server.Serve(lis)
+	}()
 
-	// Connect the new server to the network listener
-	if err = grpcServer.Serve(lis); err != nil {
-		log.Fatalf("failed to serve %v", err)
+	if server.Gateway != nil {
+		if gatewayLis, err := net.Listen("tcp", conf.Server.GatewayAddress); err != nil {
+			serveErr <- fmt.Errorf("cannot listen for REST gateway: %w", err)
+		} else {
+			slog.Info("REST gateway listening", slog.String("address", conf.Server.GatewayAddress))
+			go func() {
+				serveErr <- server.ServeGateway(gatewayLis)
+			}()
+		}
+	}
+
+	if server.Admin != nil {
+		if adminLis, err := net.Listen("tcp", conf.Server.AdminAddress); err != nil {
+			serveErr <- fmt.Errorf("cannot listen for admin HTTP: %w", err)
+		} else {
+			slog.Info("admin HTTP server listening", slog.String("address", conf.Server.AdminAddress))
+			go func() {
+				serveErr <- server.ServeAdmin(adminLis)
+			}()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	exitCode := 0
+	select {
+	case err := <-serveErr:
+		slog.Error("failed to serve", slog.Any("error", err))
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("received shutdown signal, draining", slog.Duration("timeout", conf.Server.ShutdownTimeout))
+	}
+	// Restore default signal handling so that a second signal kills the process.
+	stop()
+
+	if err := server.Shutdown(); err != nil {
+		slog.Error("failed to shut down cleanly", slog.Any("error", err))
+		exitCode = 1
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("failed to flush traces", slog.Any("error", err))
 	}
+	slog.Info("gRPC server stopped")
+	os.Exit(exitCode)
 }
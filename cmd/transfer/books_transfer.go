@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/services/booksclient"
+	"github.com/celestebrant/library-of-books/internal/transfer"
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/grpc"
+)
+
+const usage = `usage:
+  transfer export [-format csv|jsonl] [-out file] [-- config flags]
+  transfer import -in file [-format csv|jsonl] [-columns field=column,...] [-dry-run]
+                  [-errors file] [-import-id id] [-- config flags]`
+
+// main exports every book to a file, or imports books from one. Flags after "--" are
+// passed to config.Load, e.g. -client-address.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logging.Fatal("failed to "+os.Args[1]+" books", slog.Any("error", err))
+	}
+}
+
+// loadConfig loads the config from args, and sets up logging.
+func loadConfig(args []string) config.Config {
+	conf, err := config.Load(args)
+	if err != nil {
+		logging.Fatal("failed to load config", slog.Any("error", err))
+	}
+	if err := logging.SetDefault(conf.Log); err != nil {
+		logging.Fatal("failed to set up logging", slog.Any("error", err))
+	}
+	return conf
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", string(transfer.FormatCSV), `file format: "csv" or "jsonl"`)
+	out := fs.String("out", "-", `file to write, "-" for standard output`)
+	fs.Parse(args)
+	conf := loadConfig(fs.Args())
+
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	file := os.Stdout
+	if *out != "-" {
+		if file, err = os.Create(*out); err != nil {
+			return fmt.Errorf("cannot create export file: %w", err)
+		}
+		defer file.Close()
+	}
+	writer, err := transfer.NewWriter(f, file)
+	if err != nil {
+		return err
+	}
+
+	client, conn := booksclient.MustNewBooksClient(conf.Client)
+	defer conn.Close()
+
+	n, err := transfer.Export(ctx, client, writer, conf.Client.RequestTimeout)
+	if err != nil {
+		return err
+	}
+	if *out != "-" {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to close export file: %w", err)
+		}
+	}
+	slog.Info("exported books", slog.Int("books", n), slog.String("out", *out))
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "", "file to import")
+	format := fs.String("format", string(transfer.FormatCSV), `file format: "csv" or "jsonl"`)
+	columns := fs.String("columns", "", `column of each field, e.g. "title=Book Title,author=Writer", for fields not named after their column`)
+	dryRun := fs.Bool("dry-run", false, "validate the rows without importing them")
+	errorsPath := fs.String("errors", "", `CSV file listing rejected rows (default the input file with ".errors.csv" appended)`)
+	importID := fs.String("import-id", "", "ID to resume an interrupted import with (default a new ID)")
+	fs.Parse(args)
+	conf := loadConfig(fs.Args())
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	cols, err := transfer.ParseColumns(*columns)
+	if err != nil {
+		return err
+	}
+	if *importID == "" {
+		*importID = ulid.Make().String()
+	}
+	if *errorsPath == "" {
+		*errorsPath = *in + ".errors.csv"
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("cannot open import file: %w", err)
+	}
+	defer file.Close()
+	rows, err := transfer.NewReader(f, file, cols)
+	if err != nil {
+		return err
+	}
+	reportFile, err := os.Create(*errorsPath)
+	if err != nil {
+		return fmt.Errorf("cannot create error report: %w", err)
+	}
+	defer reportFile.Close()
+	report, err := transfer.NewReport(reportFile)
+	if err != nil {
+		return err
+	}
+
+	var client books.BooksClient
+	if !*dryRun {
+		var conn *grpc.ClientConn
+		client, conn = booksclient.MustNewBooksClient(conf.Client)
+		defer conn.Close()
+		slog.Info("importing books, rerun with -import-id to resume if interrupted", slog.String("import_id", *importID))
+	}
+	sum, err := transfer.Import(ctx, client, rows, transfer.ImportOptions{
+		ImportID: *importID,
+		DryRun:   *dryRun,
+		Progress: func(res *books.ImportBooksResponse) {
+			slog.Info("import progress", slog.Int64("committed", res.Committed), slog.Int64("inserted", res.Inserted))
+		},
+	}, report)
+	if err != nil {
+		return err
+	}
+	if err := reportFile.Close(); err != nil {
+		return fmt.Errorf("failed to close error report: %w", err)
+	}
+
+	attrs := []any{slog.Int("rows", sum.Rows), slog.Int("rejected", sum.Rejected), slog.String("errors", *errorsPath)}
+	if *dryRun {
+		slog.Info("validated books", attrs...)
+		return nil
+	}
+	slog.Info("imported books", append(attrs,
+		slog.String("import_id", *importID),
+		slog.Int64("inserted", sum.Inserted),
+		slog.Int64("skipped_duplicates", sum.SkippedDuplicates),
+	)...)
+	return nil
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/serverstream"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys carrying credentials.
+const (
+	APIKeyHeader        = "x-api-key"
+	AuthorizationHeader = "authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// Authentication methods recorded on a Principal.
+const (
+	MethodAPIKey     = "api_key"
+	MethodJWT        = "jwt"
+	MethodClientCert = "client_cert"
+)
+
+// publicMethodPrefixes lists RPCs that may be called without credentials, so that
+// orchestrator probes do not need them.
+var publicMethodPrefixes = []string{
+	"/grpc.health.v1.Health/",
+}
+
+// Principal is the authenticated caller of an RPC.
+type Principal struct {
+	// Subject is the API key name, the JWT "sub" claim, or the client certificate's
+	// identity as returned by CertificateIdentity.
+	Subject string
+	// Method is MethodAPIKey, MethodJWT or MethodClientCert.
+	Method string
+	// Roles are the API key's configured roles, the JWT "roles" claim, or the client
+	// certificate's organizational units.
+	Roles []string
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal authenticated for the current RPC, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// apiKey is a configured API key, identified by the SHA-256 digest of its value.
+type apiKey struct {
+	name   string
+	digest []byte
+	roles  []string
+}
+
+// claims are the JWT claims read from bearer tokens.
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// Authenticator verifies the credentials sent with each RPC against static API keys and
+// JWT bearer tokens signed by keys in a local JWKS file.
+type Authenticator struct {
+	apiKeys []apiKey
+	jwtKeys map[string]crypto.PublicKey
+	parser  *jwt.Parser
+}
+
+// NewAuthenticator loads the API keys and JWKS file configured in conf.
+func NewAuthenticator(conf config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+
+	for _, k := range conf.APIKeys {
+		digest, err := hex.DecodeString(k.KeySHA256)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: key_sha256 must be a hex-encoded SHA-256 digest", k.Name)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: k.Name, digest: digest, roles: k.Roles})
+	}
+
+	if conf.JWT.JWKSFile != "" {
+		keys, err := loadJWKS(conf.JWT.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwtKeys = keys
+
+		opts := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{
+				"RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+				"ES256", "ES384", "ES512", "EdDSA",
+			}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(conf.JWT.Leeway),
+		}
+		if conf.JWT.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(conf.JWT.Issuer))
+		}
+		if conf.JWT.Audience != "" {
+			opts = append(opts, jwt.WithAudience(conf.JWT.Audience))
+		}
+		a.parser = jwt.NewParser(opts...)
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the principal for the credentials in the incoming metadata of
+// ctx, or for the client certificate verified by mutual TLS. A bearer token takes
+// precedence over an API key, and both over a client certificate. Returns a
+// codes.Unauthenticated status error if credentials are missing or invalid.
+func (a *Authenticator) Authenticate(ctx context.Context) (Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(AuthorizationHeader); len(values) > 0 {
+		token, ok := strings.CutPrefix(values[0], bearerPrefix)
+		if !ok {
+			return Principal{}, status.Error(codes.Unauthenticated, "authorization header must use the Bearer scheme")
+		}
+		return a.authenticateJWT(ctx, token)
+	}
+
+	if values := md.Get(APIKeyHeader); len(values) > 0 {
+		return a.authenticateAPIKey(values[0])
+	}
+
+	if cert, ok := ClientCertificate(ctx); ok {
+		if subject := CertificateIdentity(cert); subject != "" {
+			return Principal{Subject: subject, Method: MethodClientCert, Roles: cert.Subject.OrganizationalUnit}, nil
+		}
+	}
+
+	return Principal{}, status.Error(codes.Unauthenticated, "missing credentials")
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (Principal, error) {
+	digest := sha256.Sum256([]byte(key))
+	for _, k := range a.apiKeys {
+		if subtle.ConstantTimeCompare(digest[:], k.digest) == 1 {
+			return Principal{Subject: k.name, Method: MethodAPIKey, Roles: k.roles}, nil
+		}
+	}
+	return Principal{}, status.Error(codes.Unauthenticated, "invalid API key")
+}
+
+func (a *Authenticator) authenticateJWT(ctx context.Context, token string) (Principal, error) {
+	if a.parser == nil {
+		return Principal{}, status.Error(codes.Unauthenticated, "bearer tokens are not accepted")
+	}
+
+	var claims claims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.keyFunc); err != nil {
+		logging.FromContext(ctx).Debug("rejected bearer token", slog.Any("error", err))
+		return Principal{}, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+	if claims.Subject == "" {
+		return Principal{}, status.Error(codes.Unauthenticated, "bearer token has no subject")
+	}
+
+	return Principal{Subject: claims.Subject, Method: MethodJWT, Roles: claims.Roles}, nil
+}
+
+// ClientCertificate returns the leaf certificate the caller of the current RPC presented
+// and the server verified, if any.
+func ClientCertificate(ctx context.Context) (*x509.Certificate, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return info.State.VerifiedChains[0][0], true
+}
+
+// CertificateIdentity returns the identity of cert: its subject common name, or else its
+// first URI (such as a SPIFFE ID) or DNS subject alternative name.
+func CertificateIdentity(cert *x509.Certificate) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+// keyFunc selects the verification key named by the token's "kid" header, or the only
+// key in the set if the token does not name one.
+func (a *Authenticator) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(a.jwtKeys) == 1 {
+		for _, key := range a.jwtKeys {
+			return key, nil
+		}
+	}
+	key, ok := a.jwtKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// UnaryServerInterceptor rejects unary RPCs without valid credentials, and attaches the
+// authenticated principal to the handler's context.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		if isPublic(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		p, err := a.Authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(NewContext(ctx, p), req)
+	}
+}
+
+// StreamServerInterceptor is the streaming equivalent of UnaryServerInterceptor.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		if isPublic(info.FullMethod) {
+			return handler(srv, stream)
+		}
+		p, err := a.Authenticate(stream.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, serverstream.WithContext(stream, NewContext(stream.Context(), p)))
+	}
+}
+
+// isPublic reports whether fullMethod may be called without credentials.
+func isPublic(fullMethod string) bool {
+	for _, prefix := range publicMethodPrefixes {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testAPIKey = "s3cret-importer-key"
+
+// writeJWKS writes the public half of key to a JWKS file under kid and returns its path.
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   enc.EncodeToString(key.N.Bytes()),
+		"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	b, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+// signToken returns an RS256 token for claims signed with key under kid.
+func signToken(t *testing.T, kid string, key *rsa.PrivateKey, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+// newTestAuthenticator returns an Authenticator accepting testAPIKey as "importer" and
+// tokens issued by "library-test" signed with key.
+func newTestAuthenticator(t *testing.T, key *rsa.PrivateKey) *Authenticator {
+	t.Helper()
+	digest := sha256.Sum256([]byte(testAPIKey))
+	a, err := NewAuthenticator(config.AuthConfig{
+		Enabled: true,
+		APIKeys: []config.APIKey{{Name: "importer", KeySHA256: hex.EncodeToString(digest[:]), Roles: []string{RoleLibrarian}}},
+		JWT: config.JWTConfig{
+			JWKSFile: writeJWKS(t, "key-1", key),
+			Issuer:   "library-test",
+		},
+	})
+	require.NoError(t, err)
+	return a
+}
+
+func incoming(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	a := newTestAuthenticator(t, key)
+
+	validClaims := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			Issuer:    "library-test",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: []string{RolePatron},
+	}
+
+	t.Run("valid API key", func(t *testing.T) {
+		r := require.New(t)
+		p, err := a.Authenticate(incoming(APIKeyHeader, testAPIKey))
+		r.NoError(err)
+		r.Equal(Principal{Subject: "importer", Method: MethodAPIKey, Roles: []string{RoleLibrarian}}, p)
+	})
+
+	t.Run("valid bearer token", func(t *testing.T) {
+		r := require.New(t)
+		token := signToken(t, "key-1", key, validClaims)
+		p, err := a.Authenticate(incoming(AuthorizationHeader, "Bearer "+token))
+		r.NoError(err)
+		r.Equal(Principal{Subject: "alice", Method: MethodJWT, Roles: []string{RolePatron}}, p)
+	})
+
+	t.Run("bearer token without kid uses only key", func(t *testing.T) {
+		r := require.New(t)
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims)
+		s, err := token.SignedString(key)
+		r.NoError(err)
+		_, err = a.Authenticate(incoming(AuthorizationHeader, "Bearer "+s))
+		r.NoError(err)
+	})
+
+	failures := []struct {
+		name string
+		ctx  func(t *testing.T) context.Context
+	}{
+		{
+			name: "missing credentials",
+			ctx:  func(*testing.T) context.Context { return context.Background() },
+		},
+		{
+			name: "wrong API key",
+			ctx:  func(*testing.T) context.Context { return incoming(APIKeyHeader, "guess") },
+		},
+		{
+			name: "non-bearer authorization",
+			ctx:  func(*testing.T) context.Context { return incoming(AuthorizationHeader, "Basic dXNlcjpwYXNz") },
+		},
+		{
+			name: "expired token",
+			ctx: func(t *testing.T) context.Context {
+				claims := validClaims
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				return incoming(AuthorizationHeader, "Bearer "+signToken(t, "key-1", key, claims))
+			},
+		},
+		{
+			name: "token without expiry",
+			ctx: func(t *testing.T) context.Context {
+				claims := validClaims
+				claims.ExpiresAt = nil
+				return incoming(AuthorizationHeader, "Bearer "+signToken(t, "key-1", key, claims))
+			},
+		},
+		{
+			name: "wrong issuer",
+			ctx: func(t *testing.T) context.Context {
+				claims := validClaims
+				claims.Issuer = "someone-else"
+				return incoming(AuthorizationHeader, "Bearer "+signToken(t, "key-1", key, claims))
+			},
+		},
+		{
+			name: "unknown kid",
+			ctx: func(t *testing.T) context.Context {
+				return incoming(AuthorizationHeader, "Bearer "+signToken(t, "key-2", key, validClaims))
+			},
+		},
+		{
+			name: "signed by another key",
+			ctx: func(t *testing.T) context.Context {
+				other, err := rsa.GenerateKey(rand.Reader, 2048)
+				require.NoError(t, err)
+				return incoming(AuthorizationHeader, "Bearer "+signToken(t, "key-1", other, validClaims))
+			},
+		},
+		{
+			name: "HMAC token",
+			ctx: func(t *testing.T) context.Context {
+				s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims).SignedString([]byte("shared"))
+				require.NoError(t, err)
+				return incoming(AuthorizationHeader, "Bearer "+s)
+			},
+		},
+	}
+	for _, tc := range failures {
+		t.Run(tc.name+" is unauthenticated", func(t *testing.T) {
+			r := require.New(t)
+			_, err := a.Authenticate(tc.ctx(t))
+			r.Equal(codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	interceptor := newTestAuthenticator(t, key).UnaryServerInterceptor()
+
+	t.Run("attaches principal to handler context", func(t *testing.T) {
+		r := require.New(t)
+		var got Principal
+		_, err := interceptor(incoming(APIKeyHeader, testAPIKey), nil,
+			&grpc.UnaryServerInfo{FullMethod: "/books.Books/CreateBook"},
+			func(ctx context.Context, _ any) (any, error) {
+				got, _ = FromContext(ctx)
+				return nil, nil
+			})
+		r.NoError(err)
+		r.Equal("importer", got.Subject)
+	})
+
+	t.Run("rejects before calling handler", func(t *testing.T) {
+		r := require.New(t)
+		called := false
+		_, err := interceptor(context.Background(), nil,
+			&grpc.UnaryServerInfo{FullMethod: "/books.Books/ListBooks"},
+			func(context.Context, any) (any, error) {
+				called = true
+				return nil, nil
+			})
+		r.Equal(codes.Unauthenticated, status.Code(err))
+		r.False(called)
+	})
+
+	t.Run("health checks need no credentials", func(t *testing.T) {
+		r := require.New(t)
+		_, err := interceptor(context.Background(), nil,
+			&grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+			func(ctx context.Context, _ any) (any, error) {
+				_, ok := FromContext(ctx)
+				r.False(ok)
+				return nil, nil
+			})
+		r.NoError(err)
+	})
+}
+
+func TestLoadJWKS(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, contents string) string {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+		return path
+	}
+
+	t.Run("only encryption keys returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := loadJWKS(write(t, `{"keys":[{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}]}`))
+		r.ErrorContains(err, "no signing keys")
+	})
+
+	t.Run("duplicate kid returns error", func(t *testing.T) {
+		r := require.New(t)
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		r.NoError(err)
+		b, err := os.ReadFile(writeJWKS(t, "dup", key))
+		r.NoError(err)
+		var set struct {
+			Keys []json.RawMessage `json:"keys"`
+		}
+		r.NoError(json.Unmarshal(b, &set))
+		set.Keys = append(set.Keys, set.Keys[0])
+		b, err = json.Marshal(set)
+		r.NoError(err)
+		_, err = loadJWKS(write(t, string(b)))
+		r.ErrorContains(err, "dup")
+	})
+
+	t.Run("unsupported key type returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := loadJWKS(write(t, `{"keys":[{"kty":"oct","kid":"k"}]}`))
+		r.Error(err)
+	})
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	digest := sha256.Sum256([]byte(testAPIKey))
+	a, err := NewAuthenticator(config.AuthConfig{
+		Enabled: true,
+		APIKeys: []config.APIKey{{Name: "importer", KeySHA256: hex.EncodeToString(digest[:])}},
+	})
+	require.NoError(t, err)
+
+	// withPeer returns a context for an RPC over a connection whose verified client
+	// certificate is cert.
+	withPeer := func(ctx context.Context, cert *x509.Certificate) context.Context {
+		info := credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: info})
+	}
+
+	t.Run("common name and organizational units", func(t *testing.T) {
+		r := require.New(t)
+		cert := &x509.Certificate{Subject: pkix.Name{
+			CommonName:         "cataloguing-service",
+			OrganizationalUnit: []string{RoleLibrarian},
+		}}
+		p, err := a.Authenticate(withPeer(context.Background(), cert))
+		r.NoError(err)
+		r.Equal(Principal{Subject: "cataloguing-service", Method: MethodClientCert, Roles: []string{RoleLibrarian}}, p)
+	})
+
+	t.Run("URI SAN without common name", func(t *testing.T) {
+		r := require.New(t)
+		id, err := url.Parse("spiffe://library.example/indexer")
+		r.NoError(err)
+		p, err := a.Authenticate(withPeer(context.Background(), &x509.Certificate{URIs: []*url.URL{id}}))
+		r.NoError(err)
+		r.Equal("spiffe://library.example/indexer", p.Subject)
+	})
+
+	t.Run("API key takes precedence", func(t *testing.T) {
+		r := require.New(t)
+		ctx := withPeer(incoming(APIKeyHeader, testAPIKey), &x509.Certificate{Subject: pkix.Name{CommonName: "svc"}})
+		p, err := a.Authenticate(ctx)
+		r.NoError(err)
+		r.Equal(MethodAPIKey, p.Method)
+	})
+
+	t.Run("unverified connection is unauthenticated", func(t *testing.T) {
+		r := require.New(t)
+		ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+		_, err := a.Authenticate(ctx)
+		r.Equal(codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+)
+
+// APIKeyCredentials sends a static API key with every RPC. It implements
+// credentials.PerRPCCredentials.
+type APIKeyCredentials struct {
+	Key string
+}
+
+func (c APIKeyCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{APIKeyHeader: c.Key}, nil
+}
+
+// RequireTransportSecurity returns false so that the key can be sent to a local server
+// without TLS. Enable client TLS whenever the server is not on the same host.
+func (c APIKeyCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// BearerTokenCredentials sends a JWT as a bearer token with every RPC. It implements
+// credentials.PerRPCCredentials.
+type BearerTokenCredentials struct {
+	Token string
+}
+
+func (c BearerTokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{AuthorizationHeader: bearerPrefix + c.Token}, nil
+}
+
+// RequireTransportSecurity returns false so that the token can be sent to a local
+// server without TLS. Enable client TLS whenever the server is not on the same host.
+func (c BearerTokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// Permission is the right to perform a class of operations.
+type Permission string
+
+// Permissions used by the built-in policy.
+const (
+	PermissionReadBooks  Permission = "books.read"
+	PermissionWriteBooks Permission = "books.write"
+)
+
+// Roles used by the built-in policy.
+const (
+	RolePatron    = "patron"
+	RoleLibrarian = "librarian"
+)
+
+// Policy decides which principals may call which RPCs. Each RPC requires a single
+// permission, and a principal holds the union of the permissions of its roles. RPCs
+// missing from Methods are denied to everyone, so new RPCs must be added explicitly.
+type Policy struct {
+	// Roles maps a role name to the permissions it grants.
+	Roles map[string][]Permission `yaml:"roles"`
+	// Methods maps a full RPC method name, such as "/Books/CreateBook", to the
+	// permission it requires.
+	Methods map[string]Permission `yaml:"methods"`
+}
+
+// DefaultPolicy returns the built-in policy: patrons may read the catalogue, and
+// librarians may also change it.
+func DefaultPolicy() Policy {
+	return Policy{
+		Roles: map[string][]Permission{
+			RolePatron:    {PermissionReadBooks},
+			RoleLibrarian: {PermissionReadBooks, PermissionWriteBooks},
+		},
+		Methods: map[string]Permission{
+			"/Books/CreateBook":       PermissionWriteBooks,
+			"/Books/BatchCreateBooks": PermissionWriteBooks,
+			"/Books/ImportBooks":      PermissionWriteBooks,
+			"/Books/ListBooks":        PermissionReadBooks,
+			"/Books/GetBook":          PermissionReadBooks,
+			"/Books/BatchGetBooks":    PermissionReadBooks,
+			"/Books/WatchBooks":       PermissionReadBooks,
+
+			"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      PermissionReadBooks,
+			"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": PermissionReadBooks,
+		},
+	}
+}
+
+// LoadPolicy reads a policy from the YAML file at path. Unknown keys are rejected.
+func LoadPolicy(path string) (Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, fmt.Errorf("cannot read policy file: %w", err)
+	}
+
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return Policy{}, fmt.Errorf("cannot parse policy file %s: %w", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return Policy{}, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// validate returns an error if a method requires a permission that no role grants, as
+// it could never be called.
+func (p Policy) validate() error {
+	granted := make(map[Permission]bool)
+	for role, perms := range p.Roles {
+		if role == "" {
+			return errors.New("role names must not be empty")
+		}
+		for _, perm := range perms {
+			granted[perm] = true
+		}
+	}
+	for method, perm := range p.Methods {
+		if perm == "" {
+			return fmt.Errorf("method %s has no permission", method)
+		}
+		if !granted[perm] {
+			return fmt.Errorf("method %s requires permission %q, which no role grants", method, perm)
+		}
+	}
+	return nil
+}
+
+// Authorize returns nil if principal may call fullMethod. Otherwise it returns a
+// codes.PermissionDenied status error.
+func (p Policy) Authorize(fullMethod string, principal Principal) error {
+	perm, ok := p.Methods[fullMethod]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "%s is not permitted by the policy", fullMethod)
+	}
+	for _, role := range principal.Roles {
+		if slices.Contains(p.Roles[role], perm) {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "%s requires permission %q", fullMethod, perm)
+}
+
+// authorizeContext authorizes the principal attached to ctx by an Authenticator.
+func (p Policy) authorizeContext(ctx context.Context, fullMethod string) error {
+	if isPublic(fullMethod) {
+		return nil
+	}
+	principal, ok := FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	return p.Authorize(fullMethod, principal)
+}
+
+// UnaryServerInterceptor rejects unary RPCs that the authenticated principal is not
+// permitted to call. It must run after the Authenticator's interceptor.
+func (p Policy) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := p.authorizeContext(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming equivalent of UnaryServerInterceptor.
+func (p Policy) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		if err := p.authorizeContext(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// writePolicyFile writes contents to a policy file in a temporary directory and returns
+// its path.
+func writePolicyFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	t.Parallel()
+	policy := DefaultPolicy()
+
+	t.Run("covers every Books method", func(t *testing.T) {
+		r := require.New(t)
+		for _, m := range books.Books_ServiceDesc.Methods {
+			r.Contains(policy.Methods, "/"+books.Books_ServiceDesc.ServiceName+"/"+m.MethodName)
+		}
+		for _, s := range books.Books_ServiceDesc.Streams {
+			r.Contains(policy.Methods, "/"+books.Books_ServiceDesc.ServiceName+"/"+s.StreamName)
+		}
+	})
+
+	tests := []struct {
+		method  string
+		allowed map[string]bool
+	}{
+		{
+			method:  "/Books/CreateBook",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: false, "": false},
+		},
+		{
+			method:  "/Books/ListBooks",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: true, "": false},
+		},
+		{
+			method:  "/Books/GetBook",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: true, "": false},
+		},
+		{
+			method:  "/Books/BatchCreateBooks",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: false, "": false},
+		},
+		{
+			method:  "/Books/ImportBooks",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: false, "": false},
+		},
+		{
+			method:  "/Books/BatchGetBooks",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: true, "": false},
+		},
+		{
+			method:  "/Books/WatchBooks",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: true, "": false},
+		},
+		{
+			method:  "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			allowed: map[string]bool{RoleLibrarian: true, RolePatron: true, "": false},
+		},
+	}
+	for _, tc := range tests {
+		for role, allowed := range tc.allowed {
+			t.Run(tc.method+" as "+role, func(t *testing.T) {
+				r := require.New(t)
+				principal := Principal{Subject: "someone"}
+				if role != "" {
+					principal.Roles = []string{role}
+				}
+				err := policy.Authorize(tc.method, principal)
+				if allowed {
+					r.NoError(err)
+				} else {
+					r.Equal(codes.PermissionDenied, status.Code(err))
+				}
+			})
+		}
+	}
+
+	t.Run("unlisted method is denied", func(t *testing.T) {
+		r := require.New(t)
+		err := policy.Authorize("/Books/DeleteBook", Principal{Roles: []string{RoleLibrarian}})
+		r.Equal(codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("unknown role grants nothing", func(t *testing.T) {
+		r := require.New(t)
+		err := policy.Authorize("/Books/ListBooks", Principal{Roles: []string{"admin"}})
+		r.Equal(codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestLoadPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file replaces default policy", func(t *testing.T) {
+		r := require.New(t)
+		policy, err := LoadPolicy(writePolicyFile(t, `
+roles:
+  cataloguer: [books.write]
+methods:
+  /Books/CreateBook: books.write
+`))
+		r.NoError(err)
+		r.NoError(policy.Authorize("/Books/CreateBook", Principal{Roles: []string{"cataloguer"}}))
+		r.Equal(codes.PermissionDenied, status.Code(
+			policy.Authorize("/Books/ListBooks", Principal{Roles: []string{"cataloguer"}}),
+		))
+	})
+
+	t.Run("unknown key returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := LoadPolicy(writePolicyFile(t, "rules: {}\n"))
+		r.ErrorContains(err, "rules")
+	})
+
+	t.Run("permission granted by no role returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := LoadPolicy(writePolicyFile(t, `
+roles:
+  patron: [books.read]
+methods:
+  /Books/CreateBook: books.write
+`))
+		r.ErrorContains(err, `method /Books/CreateBook requires permission "books.write", which no role grants`)
+	})
+}
+
+func TestPolicyUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	interceptor := DefaultPolicy().UnaryServerInterceptor()
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	t.Run("permitted call reaches handler", func(t *testing.T) {
+		r := require.New(t)
+		ctx := NewContext(context.Background(), Principal{Subject: "bob", Roles: []string{RolePatron}})
+		res, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Books/ListBooks"}, handler)
+		r.NoError(err)
+		r.Equal("ok", res)
+	})
+
+	t.Run("forbidden call is denied", func(t *testing.T) {
+		r := require.New(t)
+		ctx := NewContext(context.Background(), Principal{Subject: "bob", Roles: []string{RolePatron}})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/Books/CreateBook"}, handler)
+		r.Equal(codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("missing principal is unauthenticated", func(t *testing.T) {
+		r := require.New(t)
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/Books/ListBooks"}, handler)
+		r.Equal(codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("health checks are public", func(t *testing.T) {
+		r := require.New(t)
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+		r.NoError(err)
+	})
+}
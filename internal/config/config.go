@@ -0,0 +1,550 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/celestebrant/library-of-books/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the environment variable for every option, e.g. the flag
+// -mysql-password is read from LIBRARY_MYSQL_PASSWORD.
+const envPrefix = "LIBRARY_"
+
+// maxImportChunkSize bounds server.import_chunk_size, as each chunk is inserted with a
+// single multi-row INSERT.
+const maxImportChunkSize = 1000
+
+// Config holds the settings shared by the binaries under cmd/. Each binary reads the
+// sections it needs.
+type Config struct {
+	Server  ServerConfig        `yaml:"server"`
+	Client  ClientConfig        `yaml:"client"`
+	MySQL   storage.MysqlConfig `yaml:"mysql"`
+	Log     LogConfig           `yaml:"log"`
+	Tracing TracingConfig       `yaml:"tracing"`
+}
+
+// ServerConfig holds settings for the books gRPC server.
+type ServerConfig struct {
+	Address           string        `yaml:"address"`
+	ConnectionTimeout time.Duration `yaml:"connection_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+	TLS               TLSConfig     `yaml:"tls"`
+
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Outbox    OutboxConfig    `yaml:"outbox"`
+
+	// Reflection registers the gRPC server reflection service so that tools such as
+	// grpcurl can discover the RPCs. The descriptor set is also served by the admin
+	// server regardless.
+	Reflection bool `yaml:"reflection"`
+
+	// GatewayAddress is where the Books RPCs are served as REST/JSON. Empty disables
+	// the gateway.
+	GatewayAddress string `yaml:"gateway_address"`
+
+	// AdminAddress is where operational HTTP endpoints such as /metrics are served.
+	// Empty disables them.
+	AdminAddress string `yaml:"admin_address"`
+
+	// HealthCheckInterval is how often the database is pinged to report readiness.
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+	HealthCheckTimeout  time.Duration `yaml:"health_check_timeout"`
+
+	// WatchPollInterval is how often WatchBooks streams poll the change log for new
+	// changes.
+	WatchPollInterval time.Duration `yaml:"watch_poll_interval"`
+
+	// WatchRetention is how long changes are kept in the change log. WatchBooks cursors
+	// older than the retained changes are rejected. Zero keeps changes forever.
+	WatchRetention time.Duration `yaml:"watch_retention"`
+
+	// ImportChunkSize is how many records ImportBooks commits at a time, and so the
+	// most records a resumed import has to send again.
+	ImportChunkSize int `yaml:"import_chunk_size"`
+}
+
+// ClientConfig holds settings for clients of the books gRPC server.
+type ClientConfig struct {
+	Address        string        `yaml:"address"`
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	TLS            TLSConfig     `yaml:"tls"`
+
+	// APIKey or BearerToken, if set, is sent with every request. BearerToken takes
+	// precedence.
+	APIKey      string `yaml:"api_key"`
+	BearerToken string `yaml:"bearer_token"`
+}
+
+// AuthConfig holds settings for authenticating callers of the books server. When
+// enabled, every RPC apart from health checks needs a valid API key or bearer token.
+type AuthConfig struct {
+	Enabled bool      `yaml:"enabled"`
+	APIKeys []APIKey  `yaml:"api_keys"`
+	JWT     JWTConfig `yaml:"jwt"`
+
+	// PolicyFile is a YAML file mapping roles to permissions and RPCs to the permission
+	// they require. The built-in policy is used if it is empty.
+	PolicyFile string `yaml:"policy_file"`
+}
+
+// APIKey is a static API key. Only the hex-encoded SHA-256 digest of the key is
+// configured, so that the key itself does not need to be stored with the server.
+type APIKey struct {
+	Name      string   `yaml:"name"`
+	KeySHA256 string   `yaml:"key_sha256"`
+	Roles     []string `yaml:"roles"`
+}
+
+// JWTConfig holds settings for verifying JWT bearer tokens against the public keys in a
+// local JWKS file. Issuer and Audience are only checked if set. The caller's roles are
+// read from the "roles" claim.
+type JWTConfig struct {
+	JWKSFile string        `yaml:"jwks_file"`
+	Issuer   string        `yaml:"issuer"`
+	Audience string        `yaml:"audience"`
+	Leeway   time.Duration `yaml:"leeway"`
+}
+
+// RateLimitConfig holds settings for limiting how often each caller may call each RPC.
+// Callers are identified by their authenticated principal, or their address if they
+// are not authenticated.
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Default applies to RPCs without an entry in Methods, which is keyed by full method
+	// name such as "/Books/ListBooks".
+	Default RateLimit            `yaml:"default"`
+	Methods map[string]RateLimit `yaml:"methods"`
+
+	// DailyWriteQuota, if positive, limits how many books each caller may write with
+	// WriteMethods per UTC day. Streaming write methods are charged by their handlers as
+	// they write, rather than when the stream opens.
+	DailyWriteQuota int      `yaml:"daily_write_quota"`
+	WriteMethods    []string `yaml:"write_methods"`
+}
+
+// OutboxConfig holds settings for publishing events about changes to books. When
+// enabled, each change writes an event to the outbox table in the same transaction, and
+// a relay publishes the events to FilePath as JSON lines.
+type OutboxConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	FilePath string `yaml:"file_path"`
+
+	// PollInterval is how often the relay checks the outbox for events, and BatchSize
+	// is the most events it publishes per transaction.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+}
+
+// RateLimit is a token bucket refilled at RequestsPerSecond up to Burst tokens. A zero
+// RequestsPerSecond means no limit.
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// TLSConfig holds file paths for transport security. CertFile and KeyFile are reloaded
+// when they change on disk, so certificates can be rotated without a restart.
+//
+// Servers present CertFile and KeyFile. If CAFile is set, servers also require clients
+// to present a certificate signed by it (mutual TLS).
+//
+// Clients verify the server against CAFile, or the system roots if CAFile is empty, and
+// present CertFile and KeyFile if set. ServerName overrides the name the server's
+// certificate is verified against, which defaults to the host being dialled.
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+// Log output formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogConfig holds settings for structured logging. Level is one of "debug", "info",
+// "warn" or "error".
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Tracing exporters.
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// TracingConfig holds settings for OpenTelemetry tracing. SampleRatio is the fraction
+// of new traces that are recorded; traces started by a caller follow its decision.
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter"`
+	ServiceName  string  `yaml:"service_name"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint"`
+	OTLPInsecure bool    `yaml:"otlp_insecure"`
+	SampleRatio  float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used when no file, environment variable or flag
+// overrides a setting. It matches the local docker-compose.yaml setup.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:           "127.0.0.1:8089",
+			ConnectionTimeout: 120 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+			GatewayAddress:    "127.0.0.1:8080",
+			AdminAddress:      "127.0.0.1:9090",
+			Auth: AuthConfig{
+				JWT: JWTConfig{
+					Leeway: 30 * time.Second,
+				},
+			},
+			RateLimit: RateLimitConfig{
+				Default: RateLimit{
+					RequestsPerSecond: 50,
+					Burst:             100,
+				},
+				WriteMethods: []string{"/Books/CreateBook", "/Books/BatchCreateBooks", "/Books/ImportBooks"},
+			},
+			Outbox: OutboxConfig{
+				PollInterval: time.Second,
+				BatchSize:    100,
+			},
+
+			HealthCheckInterval: 5 * time.Second,
+			HealthCheckTimeout:  time.Second,
+			WatchPollInterval:   time.Second,
+			WatchRetention:      7 * 24 * time.Hour,
+			ImportChunkSize:     500,
+		},
+		Client: ClientConfig{
+			Address:        "127.0.0.1:8089",
+			RequestTimeout: 10 * time.Second,
+		},
+		MySQL: storage.MysqlConfig{
+			Username: "user1",
+			Password: "password1",
+			DBName:   "library",
+			Port:     3306,
+			Host:     "localhost", // binaries execute in machine, not container
+
+			Timeout:      5 * time.Second,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 30 * time.Second,
+			Collation:    "utf8mb4_general_ci",
+
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatText,
+		},
+		Tracing: TracingConfig{
+			Exporter:     TracingExporterNone,
+			ServiceName:  "library-of-books",
+			OTLPEndpoint: "localhost:4317",
+			OTLPInsecure: true,
+			SampleRatio:  1,
+		},
+	}
+}
+
+/*
+Load builds a Config from args (typically os.Args[1:]), environment variables and an
+optional YAML file. Later sources override earlier ones:
+- Default values.
+- The YAML file named by -config, or LIBRARY_CONFIG if the flag is not set.
+- Environment variables, named LIBRARY_ followed by the flag name in upper snake case.
+- Command-line flags.
+
+The result is validated before it is returned.
+*/
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("library-of-books", flag.ContinueOnError)
+	path := fs.String("config", "", "path to a YAML config file (env LIBRARY_CONFIG)")
+
+	opts := options()
+	flagValues := make(map[string]string)
+	for _, o := range opts {
+		name := o.name
+		record := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", o.usage, envName(name))
+		if o.isBool {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *path == "" {
+		*path = os.Getenv(envName("config"))
+	}
+
+	conf := Default()
+	if *path != "" {
+		if err := loadFile(*path, &conf); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, o := range opts {
+		if v, ok := os.LookupEnv(envName(o.name)); ok {
+			if err := o.set(&conf, v); err != nil {
+				return Config{}, fmt.Errorf("invalid value for environment variable %s: %w", envName(o.name), err)
+			}
+		}
+	}
+
+	for _, o := range opts {
+		if v, ok := flagValues[o.name]; ok {
+			if err := o.set(&conf, v); err != nil {
+				return Config{}, fmt.Errorf("invalid value for flag -%s: %w", o.name, err)
+			}
+		}
+	}
+
+	if err := conf.Validate(); err != nil {
+		return Config{}, err
+	}
+	return conf, nil
+}
+
+// loadFile decodes the YAML file at path over conf. Unknown keys are rejected so that
+// typos do not silently fall back to defaults.
+func loadFile(path string, conf *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(conf); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate returns an error if any setting is missing or out of range.
+func (c Config) Validate() error {
+	if err := validateAddress("server.address", c.Server.Address); err != nil {
+		return err
+	}
+	if c.Server.ConnectionTimeout <= 0 {
+		return fmt.Errorf("server.connection_timeout must be greater than zero")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("server.shutdown_timeout must not be negative")
+	}
+	if c.Server.TLS.Enabled && (c.Server.TLS.CertFile == "" || c.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("server.tls.cert_file and server.tls.key_file must be set when TLS is enabled")
+	}
+	if (c.Client.TLS.CertFile == "") != (c.Client.TLS.KeyFile == "") {
+		return fmt.Errorf("client.tls.cert_file and client.tls.key_file must be set together")
+	}
+
+	if c.Server.GatewayAddress != "" {
+		if err := validateAddress("server.gateway_address", c.Server.GatewayAddress); err != nil {
+			return err
+		}
+	}
+	if c.Server.AdminAddress != "" {
+		if err := validateAddress("server.admin_address", c.Server.AdminAddress); err != nil {
+			return err
+		}
+	}
+	if err := c.Server.Auth.validate(c.Server.TLS.Enabled && c.Server.TLS.CAFile != ""); err != nil {
+		return err
+	}
+	if err := c.Server.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.Server.Outbox.validate(); err != nil {
+		return err
+	}
+	if c.Server.HealthCheckInterval <= 0 {
+		return fmt.Errorf("server.health_check_interval must be greater than zero")
+	}
+	if c.Server.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("server.health_check_timeout must be greater than zero")
+	}
+	if c.Server.WatchPollInterval <= 0 {
+		return fmt.Errorf("server.watch_poll_interval must be greater than zero")
+	}
+	if c.Server.WatchRetention < 0 {
+		return fmt.Errorf("server.watch_retention must not be negative")
+	}
+	if c.Server.ImportChunkSize < 1 || c.Server.ImportChunkSize > maxImportChunkSize {
+		return fmt.Errorf("server.import_chunk_size must be between 1 and %d", maxImportChunkSize)
+	}
+	// ImportBooks charges a whole chunk at once, so a smaller quota could never cover one.
+	if q := c.Server.RateLimit.DailyWriteQuota; q > 0 && q < c.Server.ImportChunkSize {
+		return fmt.Errorf("server.rate_limit.daily_write_quota must be at least server.import_chunk_size")
+	}
+
+	if err := validateAddress("client.address", c.Client.Address); err != nil {
+		return err
+	}
+	if c.Client.RequestTimeout <= 0 {
+		return fmt.Errorf("client.request_timeout must be greater than zero")
+	}
+
+	if c.MySQL.Username == "" {
+		return fmt.Errorf("mysql.username must not be empty")
+	}
+	if c.MySQL.Host == "" {
+		return fmt.Errorf("mysql.host must not be empty")
+	}
+	if c.MySQL.DBName == "" {
+		return fmt.Errorf("mysql.dbname must not be empty")
+	}
+	if c.MySQL.Port == 0 || c.MySQL.Port > 65535 {
+		return fmt.Errorf("mysql.port must be between 1 and 65535")
+	}
+	if c.MySQL.MaxOpenConns < 0 || c.MySQL.MaxIdleConns < 0 {
+		return fmt.Errorf("mysql connection pool sizes must not be negative")
+	}
+	if c.MySQL.MaxIdleConns > c.MySQL.MaxOpenConns && c.MySQL.MaxOpenConns > 0 {
+		return fmt.Errorf("mysql.max_idle_conns must not exceed mysql.max_open_conns")
+	}
+	if c.MySQL.ConnMaxLifetime < 0 || c.MySQL.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("mysql connection lifetimes must not be negative")
+	}
+	if c.MySQL.Timeout < 0 || c.MySQL.ReadTimeout < 0 || c.MySQL.WriteTimeout < 0 {
+		return fmt.Errorf("mysql timeouts must not be negative")
+	}
+	switch c.MySQL.TLS {
+	case "", "false", "true", "skip-verify", "preferred":
+	default:
+		return fmt.Errorf(`mysql.tls must be one of "false", "true", "skip-verify" or "preferred"`)
+	}
+	if c.MySQL.TLSCAFile != "" && c.MySQL.TLS != "true" {
+		return fmt.Errorf(`mysql.tls must be "true" when mysql.tls_ca_file is set`)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf(`log.level must be one of "debug", "info", "warn" or "error"`)
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		return fmt.Errorf(`log.format must be "text" or "json"`)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		return fmt.Errorf(`tracing.exporter must be one of "none", "stdout" or "otlp"`)
+	}
+	if c.Tracing.ServiceName == "" {
+		return fmt.Errorf("tracing.service_name must not be empty")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// validate returns an error if authentication is enabled without any way to pass it, or
+// an API key is malformed. mutualTLS reports whether clients can authenticate with
+// certificates instead.
+func (a AuthConfig) validate(mutualTLS bool) error {
+	if a.Enabled && len(a.APIKeys) == 0 && a.JWT.JWKSFile == "" && !mutualTLS {
+		return fmt.Errorf("server.auth requires api_keys, jwt.jwks_file or server.tls.ca_file when enabled")
+	}
+	names := make(map[string]bool, len(a.APIKeys))
+	for _, k := range a.APIKeys {
+		if k.Name == "" {
+			return fmt.Errorf("server.auth.api_keys names must not be empty")
+		}
+		if names[k.Name] {
+			return fmt.Errorf("server.auth.api_keys name %q is duplicated", k.Name)
+		}
+		names[k.Name] = true
+		if digest, err := hex.DecodeString(k.KeySHA256); err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("server.auth.api_keys %q: key_sha256 must be a hex-encoded SHA-256 digest", k.Name)
+		}
+	}
+	if a.JWT.Leeway < 0 {
+		return fmt.Errorf("server.auth.jwt.leeway must not be negative")
+	}
+	return nil
+}
+
+// validate returns an error if a limit or the quota is negative, or a limit allows
+// requests without allowing a burst of at least one.
+func (r RateLimitConfig) validate() error {
+	if err := r.Default.validate("server.rate_limit.default"); err != nil {
+		return err
+	}
+	for method, limit := range r.Methods {
+		if err := limit.validate(fmt.Sprintf("server.rate_limit.methods[%s]", method)); err != nil {
+			return err
+		}
+	}
+	if r.DailyWriteQuota < 0 {
+		return fmt.Errorf("server.rate_limit.daily_write_quota must not be negative")
+	}
+	return nil
+}
+
+// validate returns an error if the outbox is enabled without a file to publish to, or
+// the relay settings are not positive.
+func (o OutboxConfig) validate() error {
+	if o.Enabled && o.FilePath == "" {
+		return fmt.Errorf("server.outbox.file_path must not be empty when enabled")
+	}
+	if o.PollInterval <= 0 {
+		return fmt.Errorf("server.outbox.poll_interval must be greater than zero")
+	}
+	if o.BatchSize < 1 {
+		return fmt.Errorf("server.outbox.batch_size must be at least 1")
+	}
+	return nil
+}
+
+func (l RateLimit) validate(field string) error {
+	if l.RequestsPerSecond < 0 {
+		return fmt.Errorf("%s.requests_per_second must not be negative", field)
+	}
+	if l.RequestsPerSecond > 0 && l.Burst < 1 {
+		return fmt.Errorf("%s.burst must be at least 1", field)
+	}
+	return nil
+}
+
+// validateAddress returns an error if address is not a host:port pair.
+func validateAddress(field, address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("%s must be of the form host:port: %w", field, err)
+	}
+	return nil
+}
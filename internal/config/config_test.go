@@ -0,0 +1,251 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes contents to a YAML file in a temporary directory and returns
+// its path.
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("no sources returns defaults", func(t *testing.T) {
+		r := require.New(t)
+		conf, err := Load(nil)
+		r.NoError(err)
+		r.Equal(Default(), conf)
+	})
+
+	t.Run("file overrides defaults", func(t *testing.T) {
+		r := require.New(t)
+		path := writeConfigFile(t, `
+server:
+  address: 0.0.0.0:9000
+  connection_timeout: 5s
+mysql:
+  host: db.internal
+  max_open_conns: 20
+`)
+		conf, err := Load([]string{"-config", path})
+		r.NoError(err)
+		r.Equal("0.0.0.0:9000", conf.Server.Address)
+		r.Equal(5*time.Second, conf.Server.ConnectionTimeout)
+		r.Equal("db.internal", conf.MySQL.Host)
+		r.Equal(20, conf.MySQL.MaxOpenConns)
+		r.Equal(Default().MySQL.Username, conf.MySQL.Username)
+	})
+
+	t.Run("environment overrides file", func(t *testing.T) {
+		r := require.New(t)
+		path := writeConfigFile(t, "mysql:\n  host: from-file\n  password: from-file\n")
+		t.Setenv("LIBRARY_CONFIG", path)
+		t.Setenv("LIBRARY_MYSQL_HOST", "from-env")
+
+		conf, err := Load(nil)
+		r.NoError(err)
+		r.Equal("from-env", conf.MySQL.Host)
+		r.Equal("from-file", conf.MySQL.Password)
+	})
+
+	t.Run("flags override environment", func(t *testing.T) {
+		r := require.New(t)
+		t.Setenv("LIBRARY_MYSQL_PORT", "3307")
+		t.Setenv("LIBRARY_CLIENT_REQUEST_TIMEOUT", "1m")
+
+		conf, err := Load([]string{"-mysql-port", "3308"})
+		r.NoError(err)
+		r.Equal(uint(3308), conf.MySQL.Port)
+		r.Equal(time.Minute, conf.Client.RequestTimeout)
+	})
+
+	t.Run("bool flag without value", func(t *testing.T) {
+		r := require.New(t)
+		conf, err := Load([]string{
+			"-server-tls-enabled",
+			"-server-tls-cert-file", "cert.pem",
+			"-server-tls-key-file", "key.pem",
+		})
+		r.NoError(err)
+		r.True(conf.Server.TLS.Enabled)
+	})
+
+	t.Run("unknown file key returns error", func(t *testing.T) {
+		r := require.New(t)
+		path := writeConfigFile(t, "mysql:\n  hostname: typo\n")
+		_, err := Load([]string{"-config", path})
+		r.ErrorContains(err, "hostname")
+	})
+
+	t.Run("malformed environment value returns error", func(t *testing.T) {
+		r := require.New(t)
+		t.Setenv("LIBRARY_MYSQL_PORT", "not-a-port")
+		_, err := Load(nil)
+		r.ErrorContains(err, "LIBRARY_MYSQL_PORT")
+	})
+
+	t.Run("invalid result returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := Load([]string{"-server-address", "8089"})
+		r.ErrorContains(err, "server.address")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults are valid", func(t *testing.T) {
+		r := require.New(t)
+		r.NoError(Default().Validate())
+	})
+
+	t.Run("server TLS without certificate returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.TLS.Enabled = true
+		r.EqualError(conf.Validate(), "server.tls.cert_file and server.tls.key_file must be set when TLS is enabled")
+	})
+
+	t.Run("client TLS without CA file is accepted", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Client.TLS.Enabled = true
+		r.NoError(conf.Validate())
+	})
+
+	t.Run("client certificate without key returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Client.TLS.CertFile = "client.pem"
+		r.EqualError(conf.Validate(), "client.tls.cert_file and client.tls.key_file must be set together")
+	})
+
+	t.Run("method rate limit without burst returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.RateLimit.Methods = map[string]RateLimit{"/Books/CreateBook": {RequestsPerSecond: 1}}
+		r.EqualError(conf.Validate(), "server.rate_limit.methods[/Books/CreateBook].burst must be at least 1")
+	})
+
+	t.Run("outbox enabled without file returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.Outbox.Enabled = true
+		r.EqualError(conf.Validate(), "server.outbox.file_path must not be empty when enabled")
+	})
+
+	t.Run("import chunk size too large returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.ImportChunkSize = 1001
+		r.EqualError(conf.Validate(), "server.import_chunk_size must be between 1 and 1000")
+	})
+
+	t.Run("negative watch retention returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.WatchRetention = -time.Hour
+		r.EqualError(conf.Validate(), "server.watch_retention must not be negative")
+	})
+
+	t.Run("daily write quota below import chunk size returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.RateLimit.DailyWriteQuota = 499
+		r.EqualError(conf.Validate(), "server.rate_limit.daily_write_quota must be at least server.import_chunk_size")
+	})
+
+	t.Run("zero connection timeout returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.ConnectionTimeout = 0
+		r.EqualError(conf.Validate(), "server.connection_timeout must be greater than zero")
+	})
+
+	t.Run("zero request timeout returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Client.RequestTimeout = 0
+		r.EqualError(conf.Validate(), "client.request_timeout must be greater than zero")
+	})
+
+	t.Run("negative shutdown timeout returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.ShutdownTimeout = -time.Second
+		r.EqualError(conf.Validate(), "server.shutdown_timeout must not be negative")
+	})
+
+	t.Run("mysql port out of range returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.MySQL.Port = 70000
+		r.EqualError(conf.Validate(), "mysql.port must be between 1 and 65535")
+	})
+
+	t.Run("empty mysql username returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.MySQL.Username = ""
+		r.EqualError(conf.Validate(), "mysql.username must not be empty")
+	})
+
+	t.Run("idle pool larger than open pool returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.MySQL.MaxOpenConns = 5
+		conf.MySQL.MaxIdleConns = 6
+		r.EqualError(conf.Validate(), "mysql.max_idle_conns must not exceed mysql.max_open_conns")
+	})
+
+	t.Run("unknown mysql TLS mode returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.MySQL.TLS = "required"
+		r.EqualError(conf.Validate(), `mysql.tls must be one of "false", "true", "skip-verify" or "preferred"`)
+	})
+
+	t.Run("unknown log format returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Log.Format = "xml"
+		r.EqualError(conf.Validate(), `log.format must be "text" or "json"`)
+	})
+
+	t.Run("sample ratio above one returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Tracing.SampleRatio = 1.5
+		r.EqualError(conf.Validate(), "tracing.sample_ratio must be between 0 and 1")
+	})
+
+	t.Run("auth enabled without credentials returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.Auth.Enabled = true
+		r.EqualError(conf.Validate(), "server.auth requires api_keys, jwt.jwks_file or server.tls.ca_file when enabled")
+	})
+
+	t.Run("malformed API key digest returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.Server.Auth.APIKeys = []APIKey{{Name: "importer", KeySHA256: "abc"}}
+		r.EqualError(conf.Validate(), `server.auth.api_keys "importer": key_sha256 must be a hex-encoded SHA-256 digest`)
+	})
+
+	t.Run("negative pool size returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := Default()
+		conf.MySQL.MaxIdleConns = -1
+		r.EqualError(conf.Validate(), "mysql connection pool sizes must not be negative")
+	})
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// option binds a command-line flag, and its environment variable, to a Config field.
+type option struct {
+	name   string
+	usage  string
+	isBool bool
+	set    func(c *Config, v string) error
+}
+
+// options returns every setting that can be overridden by flag or environment variable.
+func options() []option {
+	return []option{
+		stringOption("server-address", "address the server listens on", func(c *Config) *string { return &c.Server.Address }),
+		durationOption("server-connection-timeout", "timeout for new connections to complete their handshake", func(c *Config) *time.Duration { return &c.Server.ConnectionTimeout }),
+		durationOption("server-shutdown-timeout", "time to drain in-flight requests before stopping forcefully", func(c *Config) *time.Duration { return &c.Server.ShutdownTimeout }),
+		boolOption("server-reflection", "register the gRPC server reflection service", func(c *Config) *bool { return &c.Server.Reflection }),
+		stringOption("server-gateway-address", "REST/JSON gateway listen address, empty to disable", func(c *Config) *string { return &c.Server.GatewayAddress }),
+		stringOption("server-admin-address", "address for operational HTTP endpoints such as /metrics, empty to disable", func(c *Config) *string { return &c.Server.AdminAddress }),
+		durationOption("server-health-check-interval", "how often the database is pinged to report readiness", func(c *Config) *time.Duration { return &c.Server.HealthCheckInterval }),
+		durationOption("server-health-check-timeout", "timeout for each database ping", func(c *Config) *time.Duration { return &c.Server.HealthCheckTimeout }),
+		durationOption("server-watch-poll-interval", "how often WatchBooks streams poll for new changes", func(c *Config) *time.Duration { return &c.Server.WatchPollInterval }),
+		durationOption("server-watch-retention", "how long changes are kept for WatchBooks, 0 to keep them forever", func(c *Config) *time.Duration { return &c.Server.WatchRetention }),
+		intOption("server-import-chunk-size", "how many records ImportBooks commits at a time", func(c *Config) *int { return &c.Server.ImportChunkSize }),
+		boolOption("server-auth-enabled", "require an API key or bearer token for every RPC", func(c *Config) *bool { return &c.Server.Auth.Enabled }),
+		stringOption("server-auth-jwks-file", "JWKS file with the public keys that sign bearer tokens", func(c *Config) *string { return &c.Server.Auth.JWT.JWKSFile }),
+		stringOption("server-auth-jwt-issuer", "required bearer token issuer", func(c *Config) *string { return &c.Server.Auth.JWT.Issuer }),
+		stringOption("server-auth-policy-file", "YAML file mapping roles to permissions and RPCs to required permissions", func(c *Config) *string { return &c.Server.Auth.PolicyFile }),
+		stringOption("server-auth-jwt-audience", "required bearer token audience", func(c *Config) *string { return &c.Server.Auth.JWT.Audience }),
+		boolOption("server-rate-limit-enabled", "limit how often each caller may call each RPC", func(c *Config) *bool { return &c.Server.RateLimit.Enabled }),
+		floatOption("server-rate-limit-rps", "default requests per second allowed per caller and RPC", func(c *Config) *float64 { return &c.Server.RateLimit.Default.RequestsPerSecond }),
+		intOption("server-rate-limit-burst", "default burst of requests allowed per caller and RPC", func(c *Config) *int { return &c.Server.RateLimit.Default.Burst }),
+		intOption("server-rate-limit-daily-write-quota", "writes allowed per caller per UTC day, 0 for unlimited", func(c *Config) *int { return &c.Server.RateLimit.DailyWriteQuota }),
+		boolOption("server-outbox-enabled", "publish events about changes to books", func(c *Config) *bool { return &c.Server.Outbox.Enabled }),
+		stringOption("server-outbox-file-path", "JSON Lines file that book change events are published to", func(c *Config) *string { return &c.Server.Outbox.FilePath }),
+		durationOption("server-outbox-poll-interval", "how often the outbox relay checks for events", func(c *Config) *time.Duration { return &c.Server.Outbox.PollInterval }),
+		boolOption("server-tls-enabled", "serve over TLS", func(c *Config) *bool { return &c.Server.TLS.Enabled }),
+		stringOption("server-tls-cert-file", "server certificate PEM file", func(c *Config) *string { return &c.Server.TLS.CertFile }),
+		stringOption("server-tls-key-file", "server private key PEM file", func(c *Config) *string { return &c.Server.TLS.KeyFile }),
+		stringOption("server-tls-client-ca-file", "CA bundle PEM file used to verify client certificates, enabling mutual TLS", func(c *Config) *string { return &c.Server.TLS.CAFile }),
+
+		stringOption("client-address", "address of the server to dial", func(c *Config) *string { return &c.Client.Address }),
+		durationOption("client-request-timeout", "timeout for each request", func(c *Config) *time.Duration { return &c.Client.RequestTimeout }),
+		stringOption("client-api-key", "API key sent with every request", func(c *Config) *string { return &c.Client.APIKey }),
+		stringOption("client-bearer-token", "JWT sent as a bearer token with every request", func(c *Config) *string { return &c.Client.BearerToken }),
+		boolOption("client-tls-enabled", "dial over TLS", func(c *Config) *bool { return &c.Client.TLS.Enabled }),
+		stringOption("client-tls-ca-file", "CA bundle PEM file used to verify the server", func(c *Config) *string { return &c.Client.TLS.CAFile }),
+		stringOption("client-tls-cert-file", "client certificate PEM file presented for mutual TLS", func(c *Config) *string { return &c.Client.TLS.CertFile }),
+		stringOption("client-tls-key-file", "client private key PEM file presented for mutual TLS", func(c *Config) *string { return &c.Client.TLS.KeyFile }),
+		stringOption("client-tls-server-name", "name to verify the server certificate against", func(c *Config) *string { return &c.Client.TLS.ServerName }),
+
+		stringOption("mysql-username", "MySQL username", func(c *Config) *string { return &c.MySQL.Username }),
+		stringOption("mysql-password", "MySQL password", func(c *Config) *string { return &c.MySQL.Password }),
+		stringOption("mysql-host", "MySQL host", func(c *Config) *string { return &c.MySQL.Host }),
+		uintOption("mysql-port", "MySQL port", func(c *Config) *uint { return &c.MySQL.Port }),
+		stringOption("mysql-dbname", "MySQL database name", func(c *Config) *string { return &c.MySQL.DBName }),
+		durationOption("mysql-timeout", "timeout for establishing a connection", func(c *Config) *time.Duration { return &c.MySQL.Timeout }),
+		durationOption("mysql-read-timeout", "timeout for each read on a connection", func(c *Config) *time.Duration { return &c.MySQL.ReadTimeout }),
+		durationOption("mysql-write-timeout", "timeout for each write on a connection", func(c *Config) *time.Duration { return &c.MySQL.WriteTimeout }),
+		stringOption("mysql-charset", "connection character set, empty for the server default", func(c *Config) *string { return &c.MySQL.Charset }),
+		stringOption("mysql-collation", "connection collation", func(c *Config) *string { return &c.MySQL.Collation }),
+		stringOption("mysql-tls", `TLS mode: "false", "true", "skip-verify" or "preferred"`, func(c *Config) *string { return &c.MySQL.TLS }),
+		stringOption("mysql-tls-ca-file", "CA bundle PEM file used to verify the MySQL server", func(c *Config) *string { return &c.MySQL.TLSCAFile }),
+		intOption("mysql-max-open-conns", "maximum open connections, 0 for unlimited", func(c *Config) *int { return &c.MySQL.MaxOpenConns }),
+		intOption("mysql-max-idle-conns", "maximum idle connections, 0 for the database/sql default", func(c *Config) *int { return &c.MySQL.MaxIdleConns }),
+		durationOption("mysql-conn-max-lifetime", "maximum lifetime of a connection, 0 for unlimited", func(c *Config) *time.Duration { return &c.MySQL.ConnMaxLifetime }),
+		durationOption("mysql-conn-max-idle-time", "maximum time a connection may sit idle, 0 for unlimited", func(c *Config) *time.Duration { return &c.MySQL.ConnMaxIdleTime }),
+
+		stringOption("log-level", `minimum log level: "debug", "info", "warn" or "error"`, func(c *Config) *string { return &c.Log.Level }),
+		stringOption("log-format", `log output format: "text" or "json"`, func(c *Config) *string { return &c.Log.Format }),
+
+		stringOption("tracing-exporter", `trace exporter: "none", "stdout" or "otlp"`, func(c *Config) *string { return &c.Tracing.Exporter }),
+		stringOption("tracing-service-name", "service name reported with every span", func(c *Config) *string { return &c.Tracing.ServiceName }),
+		stringOption("tracing-otlp-endpoint", "host:port of the OTLP gRPC collector", func(c *Config) *string { return &c.Tracing.OTLPEndpoint }),
+		boolOption("tracing-otlp-insecure", "connect to the OTLP collector without TLS", func(c *Config) *bool { return &c.Tracing.OTLPInsecure }),
+		floatOption("tracing-sample-ratio", "fraction of new traces to record, from 0 to 1", func(c *Config) *float64 { return &c.Tracing.SampleRatio }),
+	}
+}
+
+// envName returns the environment variable for a flag name, e.g. "mysql-host" becomes
+// "LIBRARY_MYSQL_HOST".
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func stringOption(name, usage string, field func(*Config) *string) option {
+	return option{name: name, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func boolOption(name, usage string, field func(*Config) *bool) option {
+	return option{name: name, usage: usage, isBool: true, set: func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func intOption(name, usage string, field func(*Config) *int) option {
+	return option{name: name, usage: usage, set: func(c *Config, v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = i
+		return nil
+	}}
+}
+
+func uintOption(name, usage string, field func(*Config) *uint) option {
+	return option{name: name, usage: usage, set: func(c *Config, v string) error {
+		u, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return err
+		}
+		*field(c) = uint(u)
+		return nil
+	}}
+}
+
+func floatOption(name, usage string, field func(*Config) *float64) option {
+	return option{name: name, usage: usage, set: func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = f
+		return nil
+	}}
+}
+
+func durationOption(name, usage string, field func(*Config) *time.Duration) option {
+	return option{name: name, usage: usage, set: func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}}
+}
@@ -1,9 +1,112 @@
+-- IDs are compared exactly, byte for byte, rather than with the default collation,
+-- which ignores case and accents.
 CREATE TABLE books
 (
-    `id` VARCHAR(30),
+    `id` VARCHAR(30) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_bin,
     `creation_time` DATETIME(6) DEFAULT NULL,
     `update_time` DATETIME(6) DEFAULT NULL,
     `title` VARCHAR(255) DEFAULT NULL,
     `author` VARCHAR(255) DEFAULT NULL,
     PRIMARY KEY (id)
-);
\ No newline at end of file
+);
+
+-- book_changes is the change log read by WatchBooks. Each row is written in the same
+-- transaction as the change to books it records, with the book as it was after the
+-- change, or before it for a deletion.
+CREATE TABLE book_changes
+(
+    `sequence` BIGINT UNSIGNED NOT NULL,
+    `change_type` VARCHAR(10) NOT NULL,
+    `change_time` DATETIME(6) NOT NULL,
+    `book_id` VARCHAR(30) NOT NULL,
+    `title` VARCHAR(255) DEFAULT NULL,
+    `author` VARCHAR(255) DEFAULT NULL,
+    `creation_time` DATETIME(6) DEFAULT NULL,
+    PRIMARY KEY (sequence)
+);
+
+-- book_change_sequence holds the last sequence number given to a change. Writers
+-- increment it inside their transaction, which locks the row until they commit, so
+-- changes commit in sequence order and readers polling for later sequence numbers never
+-- skip one that commits late.
+CREATE TABLE book_change_sequence
+(
+    `id` TINYINT NOT NULL,
+    `sequence` BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT INTO book_change_sequence (`id`, `sequence`) VALUES (1, 0);
+
+-- book_change_retention holds the last sequence number pruned from book_changes, which
+-- is raised before the changes are deleted, so that WatchBooks can reject cursors older
+-- than the retained changes. It is kept apart from book_change_sequence so that pruning
+-- does not wait for the writers locking that row.
+CREATE TABLE book_change_retention
+(
+    `id` TINYINT NOT NULL,
+    `pruned_sequence` BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT INTO book_change_retention (`id`, `pruned_sequence`) VALUES (1, 0);
+
+-- outbox holds events for other systems about changes to books, written in the same
+-- transaction as the change. The outbox relay publishes them in sequence order and
+-- deletes them once published. Consumers discard duplicates by event_id.
+CREATE TABLE outbox
+(
+    `sequence` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `event_id` VARCHAR(26) NOT NULL,
+    `event_type` VARCHAR(30) NOT NULL,
+    `event_key` VARCHAR(30) NOT NULL,
+    `event_time` DATETIME(6) NOT NULL,
+    `payload` JSON NOT NULL,
+    PRIMARY KEY (sequence),
+    UNIQUE KEY (event_id)
+);
+
+-- outbox_lease has a single row, which a relay locks while it publishes, so that relays
+-- sharing the outbox take turns without locking the outbox itself, where writers insert.
+CREATE TABLE outbox_lease
+(
+    `id` TINYINT NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT INTO outbox_lease (`id`) VALUES (1);
+
+-- imports holds the progress of each ImportBooks import. Records are committed in
+-- chunks, and committed counts the records in the chunks committed so far, which is
+-- where a resumed import continues from.
+CREATE TABLE imports
+(
+    `import_id` VARCHAR(30) NOT NULL,
+    `committed` BIGINT NOT NULL,
+    `inserted` BIGINT NOT NULL,
+    `skipped_duplicates` BIGINT NOT NULL,
+    `rejected` BIGINT NOT NULL,
+    `update_time` DATETIME(6) NOT NULL,
+    PRIMARY KEY (import_id)
+);
+
+-- import_rejections holds the records of an import that failed validation, by their
+-- position in the import.
+CREATE TABLE import_rejections
+(
+    `import_id` VARCHAR(30) NOT NULL,
+    `record_offset` BIGINT NOT NULL,
+    `reason` VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (import_id, record_offset)
+);
+
+-- schema_migrations records the migrations in internal/db/migrations that the schema
+-- includes. This file creates the schema at the latest version.
+CREATE TABLE schema_migrations
+(
+    `version` INT NOT NULL,
+    `applied_time` DATETIME(6) NOT NULL,
+    PRIMARY KEY (version)
+);
+
+INSERT INTO schema_migrations (`version`, `applied_time`) VALUES (1, NOW(6)), (2, NOW(6));
@@ -0,0 +1,94 @@
+-- Migration 1 upgrades a database created with the original schema, which had only the
+-- books table, to the schema in init.sql. It can be run more than once. Databases
+-- created from init.sql are already at version 1.
+
+-- IDs are compared exactly, byte for byte, rather than with the default collation,
+-- which ignores case and accents. GetBook and BatchGetBooks no longer find a book by an
+-- ID that differs from its own only in case.
+ALTER TABLE books MODIFY `id` VARCHAR(30) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_bin;
+
+-- book_changes is the change log read by WatchBooks. Each row is written in the same
+-- transaction as the change to books it records, with the book as it was after the
+-- change, or before it for a deletion.
+CREATE TABLE IF NOT EXISTS book_changes
+(
+    `sequence` BIGINT UNSIGNED NOT NULL,
+    `change_type` VARCHAR(10) NOT NULL,
+    `change_time` DATETIME(6) NOT NULL,
+    `book_id` VARCHAR(30) NOT NULL,
+    `title` VARCHAR(255) DEFAULT NULL,
+    `author` VARCHAR(255) DEFAULT NULL,
+    `creation_time` DATETIME(6) DEFAULT NULL,
+    PRIMARY KEY (sequence)
+);
+
+-- book_change_sequence holds the last sequence number given to a change. Writers
+-- increment it inside their transaction, which locks the row until they commit, so
+-- changes commit in sequence order and readers polling for later sequence numbers never
+-- skip one that commits late.
+CREATE TABLE IF NOT EXISTS book_change_sequence
+(
+    `id` TINYINT NOT NULL,
+    `sequence` BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT IGNORE INTO book_change_sequence (`id`, `sequence`) VALUES (1, 0);
+
+-- outbox holds events for other systems about changes to books, written in the same
+-- transaction as the change. The outbox relay publishes them in sequence order and
+-- deletes them once published. Consumers discard duplicates by event_id.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    `sequence` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    `event_id` VARCHAR(26) NOT NULL,
+    `event_type` VARCHAR(30) NOT NULL,
+    `event_key` VARCHAR(30) NOT NULL,
+    `event_time` DATETIME(6) NOT NULL,
+    `payload` JSON NOT NULL,
+    PRIMARY KEY (sequence),
+    UNIQUE KEY (event_id)
+);
+
+-- outbox_lease has a single row, which a relay locks while it publishes, so that relays
+-- sharing the outbox take turns without locking the outbox itself, where writers insert.
+CREATE TABLE IF NOT EXISTS outbox_lease
+(
+    `id` TINYINT NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT IGNORE INTO outbox_lease (`id`) VALUES (1);
+
+-- imports holds the progress of each ImportBooks import. Records are committed in
+-- chunks, and committed counts the records in the chunks committed so far, which is
+-- where a resumed import continues from.
+CREATE TABLE IF NOT EXISTS imports
+(
+    `import_id` VARCHAR(30) NOT NULL,
+    `committed` BIGINT NOT NULL,
+    `inserted` BIGINT NOT NULL,
+    `skipped_duplicates` BIGINT NOT NULL,
+    `rejected` BIGINT NOT NULL,
+    `update_time` DATETIME(6) NOT NULL,
+    PRIMARY KEY (import_id)
+);
+
+-- import_rejections holds the records of an import that failed validation, by their
+-- position in the import.
+CREATE TABLE IF NOT EXISTS import_rejections
+(
+    `import_id` VARCHAR(30) NOT NULL,
+    `record_offset` BIGINT NOT NULL,
+    `reason` VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (import_id, record_offset)
+);
+
+CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    `version` INT NOT NULL,
+    `applied_time` DATETIME(6) NOT NULL,
+    PRIMARY KEY (version)
+);
+
+INSERT IGNORE INTO schema_migrations (`version`, `applied_time`) VALUES (1, NOW(6));
@@ -0,0 +1,17 @@
+-- Migration 2 adds the table recording how far the change log has been pruned to the
+-- schema at version 1. It can be run more than once.
+
+-- book_change_retention holds the last sequence number pruned from book_changes, which
+-- is raised before the changes are deleted, so that WatchBooks can reject cursors older
+-- than the retained changes. It is kept apart from book_change_sequence so that pruning
+-- does not wait for the writers locking that row.
+CREATE TABLE IF NOT EXISTS book_change_retention
+(
+    `id` TINYINT NOT NULL,
+    `pruned_sequence` BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT IGNORE INTO book_change_retention (`id`, `pruned_sequence`) VALUES (1, 0);
+
+INSERT IGNORE INTO schema_migrations (`version`, `applied_time`) VALUES (2, NOW(6));
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/celestebrant/library-of-books/internal/serverstream"
+	"github.com/oklog/ulid/v2"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDHeader is the metadata key carrying the request ID. Callers may set it
+	// to correlate their own logs with the server's; otherwise one is generated. The
+	// server echoes it back in the response header.
+	RequestIDHeader = "x-request-id"
+
+	// requestIDMaxLength bounds caller-supplied request IDs so that they cannot bloat
+	// every log line. Longer IDs are replaced with a generated one.
+	requestIDMaxLength = 128
+
+	// healthMethodPrefix identifies health checks, which are logged at debug level
+	// because probes call them every few seconds.
+	healthMethodPrefix = "/grpc.health.v1.Health/"
+)
+
+type requestIDKey struct{}
+
+// RequestID returns the request ID assigned by the logging interceptors, or an empty
+// string outside of a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// UnaryServerInterceptor logs the method, peer, status code, latency and request ID of
+// every unary RPC, and makes a logger carrying the request ID available to the handler
+// through FromContext.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, id := withRequestID(ctx, logger)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor is the streaming equivalent of UnaryServerInterceptor. The
+// call is logged once the stream ends.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(
+		srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		ctx, id := withRequestID(stream.Context(), logger)
+		_ = stream.SetHeader(metadata.Pairs(RequestIDHeader, id))
+
+		start := time.Now()
+		err := handler(srv, serverstream.WithContext(stream, ctx))
+		logCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// withRequestID takes the request ID from the incoming metadata, or generates one, and
+// returns a context carrying it and a logger tagged with it and the trace ID, if any.
+func withRequestID(ctx context.Context, logger *slog.Logger) (context.Context, string) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" || len(id) > requestIDMaxLength {
+		id = ulid.Make().String()
+	}
+
+	attrs := []any{slog.String("request_id", id)}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx = newContext(ctx, logger.With(attrs...))
+	return ctx, id
+}
+
+// logCall logs the outcome of an RPC with the request-scoped logger in ctx.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+
+	FromContext(ctx).LogAttrs(ctx, callLevel(method, code), "finished call", attrs...)
+}
+
+// callLevel returns the level to log a call at: debug for health checks, error for
+// codes that indicate a server-side fault, warn for other failures, info otherwise.
+func callLevel(method string, code codes.Code) slog.Level {
+	switch {
+	case strings.HasPrefix(method, healthMethodPrefix) && code == codes.OK:
+		return slog.LevelDebug
+	case code == codes.OK:
+		return slog.LevelInfo
+	case code == codes.Unknown, code == codes.Internal, code == codes.Unavailable,
+		code == codes.DataLoss, code == codes.DeadlineExceeded, code == codes.Unimplemented:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// newLogBuffer returns a buffer for a JSON logger to write to, and a function that
+// decodes the single entry written to it.
+func newLogBuffer(t *testing.T) (*bytes.Buffer, func() map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	return &buf, func() map[string]any {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		return entry
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/Books/ListBooks"}
+	peerCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000},
+	})
+
+	t.Run("logs call with propagated request ID", func(t *testing.T) {
+		r, a := require.New(t), assert.New(t)
+		buf, entry := newLogBuffer(t)
+		logger, err := NewLogger(buf, config.LogConfig{Level: "debug", Format: config.LogFormatJSON})
+		r.NoError(err)
+
+		ctx := metadata.NewIncomingContext(peerCtx, metadata.Pairs(RequestIDHeader, "req-123"))
+		var handlerRequestID string
+		_, err = UnaryServerInterceptor(logger)(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+			handlerRequestID = RequestID(ctx)
+			return "ok", nil
+		})
+		r.NoError(err)
+
+		a.Equal("req-123", handlerRequestID)
+		e := entry()
+		a.Equal("INFO", e["level"])
+		a.Equal("/Books/ListBooks", e["method"])
+		a.Equal("OK", e["code"])
+		a.Equal("req-123", e["request_id"])
+		a.Equal("10.0.0.1:5000", e["peer"])
+		a.Contains(e, "latency")
+	})
+
+	t.Run("generates request ID and logs error code", func(t *testing.T) {
+		r, a := require.New(t), assert.New(t)
+		buf, entry := newLogBuffer(t)
+		logger, err := NewLogger(buf, config.LogConfig{Level: "debug", Format: config.LogFormatJSON})
+		r.NoError(err)
+
+		_, err = UnaryServerInterceptor(logger)(peerCtx, nil, info, func(context.Context, any) (any, error) {
+			return nil, status.Error(codes.InvalidArgument, "bad page size")
+		})
+		r.Equal(codes.InvalidArgument, status.Code(err))
+
+		e := entry()
+		a.Equal("WARN", e["level"])
+		a.Equal("InvalidArgument", e["code"])
+		a.Equal("bad page size", e["error"])
+		a.NotEmpty(e["request_id"])
+	})
+
+	t.Run("health checks below configured level are not logged", func(t *testing.T) {
+		r := require.New(t)
+		var buf bytes.Buffer
+		logger, err := NewLogger(&buf, config.LogConfig{Level: "info", Format: config.LogFormatText})
+		r.NoError(err)
+
+		healthInfo := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+		_, err = UnaryServerInterceptor(logger)(peerCtx, nil, healthInfo, func(context.Context, any) (any, error) {
+			return nil, nil
+		})
+		r.NoError(err)
+		r.Empty(buf.String())
+	})
+}
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown level returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := NewLogger(&bytes.Buffer{}, config.LogConfig{Level: "verbose", Format: config.LogFormatText})
+		r.Error(err)
+	})
+
+	t.Run("unknown format returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := NewLogger(&bytes.Buffer{}, config.LogConfig{Level: "info", Format: "xml"})
+		r.EqualError(err, `unknown log format "xml"`)
+	})
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+)
+
+// NewLogger returns a logger writing to w at the level and in the format set by conf.
+func NewLogger(w io.Writer, conf config.LogConfig) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(conf.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch conf.Format {
+	case config.LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case config.LogFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", conf.Format)
+	}
+}
+
+// SetDefault installs a logger writing to stderr as the default for both slog and the
+// standard log package.
+func SetDefault(conf config.LogConfig) error {
+	logger, err := NewLogger(os.Stderr, conf)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Fatal logs msg and args at error level with the default logger, then exits with
+// status 1.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type loggerKey struct{}
+
+// FromContext returns the request-scoped logger stored by the logging interceptors, or
+// the default logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// newContext returns a copy of ctx carrying logger.
+func newContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
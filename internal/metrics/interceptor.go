@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor counts every unary RPC by status code and records its latency.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		m.observeCall(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor counts every streaming RPC by status code and records its
+// latency, measured until the stream ends.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		m.observeCall(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observeCall(fullMethod string, start time.Time, err error) {
+	service, method := splitMethod(fullMethod)
+	m.handled.WithLabelValues(service, method, status.Code(err).String()).Inc()
+	m.handling.WithLabelValues(service, method).Observe(time.Since(start).Seconds())
+}
+
+// splitMethod splits a full method name like "/Books/ListBooks" into its service and
+// method names.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", "unknown"
+	}
+	return service, method
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// countTimeout bounds the query run on each scrape to report the total number of books.
+const countTimeout = 2 * time.Second
+
+// Metrics holds the Prometheus collectors for the books server in its own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	handled       *prometheus.CounterVec
+	handling      *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+}
+
+// New returns Metrics with the gRPC and storage collectors, plus the standard Go runtime
+// and process collectors, registered.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Latency of RPCs handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_query_duration_seconds",
+			Help:    "Latency of storage operations.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		m.handled,
+		m.handling,
+		m.queryDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns an HTTP handler serving the metrics in the Prometheus exposition
+// format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveQuery records the latency of a storage operation. It implements
+// storage.QueryObserver.
+func (m *Metrics) ObserveQuery(operation string, duration time.Duration, err error) {
+	m.queryDuration.WithLabelValues(operation, queryResult(err)).Observe(duration.Seconds())
+}
+
+// queryResult classifies a storage error for the result label.
+func queryResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, sql.ErrNoRows):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// Storage is the subset of storage.MysqlStorage reported on by RegisterStorage.
+type Storage interface {
+	Stats() sql.DBStats
+	CountBooks(ctx context.Context) (int64, error)
+}
+
+// RegisterStorage registers collectors for the connection pool statistics and the total
+// number of books in s. Both are read on each scrape.
+func (m *Metrics) RegisterStorage(s Storage) {
+	m.registry.MustRegister(&storageCollector{storage: s})
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeStorage reports fixed pool statistics and book count.
+type fakeStorage struct {
+	count    int64
+	countErr error
+}
+
+func (s *fakeStorage) Stats() sql.DBStats {
+	return sql.DBStats{MaxOpenConnections: 20, OpenConnections: 3, InUse: 1, Idle: 2}
+}
+
+func (s *fakeStorage) CountBooks(context.Context) (int64, error) {
+	return s.count, s.countErr
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/Books/CreateBook"}
+
+	_, err := interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+	r.NoError(err)
+	_, err = interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.InvalidArgument, "missing author")
+	})
+	r.Error(err)
+
+	r.Equal(1.0, testutil.ToFloat64(m.handled.WithLabelValues("Books", "CreateBook", "OK")))
+	r.Equal(1.0, testutil.ToFloat64(m.handled.WithLabelValues("Books", "CreateBook", "InvalidArgument")))
+	r.Equal(1, testutil.CollectAndCount(m.handling))
+}
+
+func TestObserveQuery(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	m := New()
+	m.ObserveQuery("GetBook", time.Millisecond, nil)
+	m.ObserveQuery("GetBook", time.Millisecond, sql.ErrNoRows)
+	m.ObserveQuery("CreateBook", time.Millisecond, errors.New("duplicate key"))
+
+	// One series per operation and result.
+	r.Equal(3, testutil.CollectAndCount(m.queryDuration))
+	r.Equal("ok", queryResult(nil))
+	r.Equal("not_found", queryResult(sql.ErrNoRows))
+	r.Equal("error", queryResult(errors.New("duplicate key")))
+}
+
+func TestStorageCollector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports pool statistics and book count", func(t *testing.T) {
+		r := require.New(t)
+		c := &storageCollector{storage: &fakeStorage{count: 42}}
+		r.NoError(testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP library_books Total number of books in the catalogue.
+# TYPE library_books gauge
+library_books 42
+# HELP mysql_pool_in_use_connections Number of connections currently in use.
+# TYPE mysql_pool_in_use_connections gauge
+mysql_pool_in_use_connections 1
+`), "library_books", "mysql_pool_in_use_connections"))
+	})
+
+	t.Run("count failure returns collection error", func(t *testing.T) {
+		r := require.New(t)
+		c := &storageCollector{storage: &fakeStorage{countErr: errors.New("connection refused")}}
+		_, err := testutil.CollectAndLint(c)
+		r.Error(err)
+	})
+}
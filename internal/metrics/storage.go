@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	maxOpenConnsDesc = prometheus.NewDesc(
+		"mysql_pool_max_open_connections", "Maximum number of open connections to the database.", nil, nil)
+	openConnsDesc = prometheus.NewDesc(
+		"mysql_pool_open_connections", "Number of established connections, both in use and idle.", nil, nil)
+	inUseConnsDesc = prometheus.NewDesc(
+		"mysql_pool_in_use_connections", "Number of connections currently in use.", nil, nil)
+	idleConnsDesc = prometheus.NewDesc(
+		"mysql_pool_idle_connections", "Number of idle connections.", nil, nil)
+	waitCountDesc = prometheus.NewDesc(
+		"mysql_pool_wait_count_total", "Total number of connections waited for.", nil, nil)
+	waitDurationDesc = prometheus.NewDesc(
+		"mysql_pool_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", nil, nil)
+	maxIdleClosedDesc = prometheus.NewDesc(
+		"mysql_pool_max_idle_closed_total", "Total number of connections closed due to max_idle_conns.", nil, nil)
+	maxIdleTimeClosedDesc = prometheus.NewDesc(
+		"mysql_pool_max_idle_time_closed_total", "Total number of connections closed due to conn_max_idle_time.", nil, nil)
+	maxLifetimeClosedDesc = prometheus.NewDesc(
+		"mysql_pool_max_lifetime_closed_total", "Total number of connections closed due to conn_max_lifetime.", nil, nil)
+	booksDesc = prometheus.NewDesc(
+		"library_books", "Total number of books in the catalogue.", nil, nil)
+)
+
+// storageCollector reports connection pool statistics and catalogue size at scrape time.
+type storageCollector struct {
+	storage Storage
+}
+
+func (c *storageCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		maxOpenConnsDesc, openConnsDesc, inUseConnsDesc, idleConnsDesc, waitCountDesc,
+		waitDurationDesc, maxIdleClosedDesc, maxIdleTimeClosedDesc, maxLifetimeClosedDesc, booksDesc,
+	} {
+		ch <- d
+	}
+}
+
+func (c *storageCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.storage.Stats()
+	ch <- prometheus.MustNewConstMetric(maxOpenConnsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(openConnsDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(inUseConnsDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(idleConnsDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(waitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(waitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(maxIdleClosedDesc, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(maxIdleTimeClosedDesc, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(maxLifetimeClosedDesc, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+
+	ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+	defer cancel()
+	n, err := c.storage.CountBooks(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(booksDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(booksDesc, prometheus.GaugeValue, float64(n))
+}
@@ -0,0 +1,89 @@
+// Package openapi post-processes the OpenAPI v3 document that protoc-gen-openapi
+// generates from books.proto.
+package openapi
+
+import (
+	"fmt"
+	"strings"
+
+	openapiv3 "github.com/google/gnostic-models/openapiv3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// header is the comment protoc-gen-openapi starts its documents with.
+const header = "Generated with protoc-gen-openapi\nhttps://github.com/google/gnostic/tree/master/cmd/protoc-gen-openapi"
+
+// ApplyParameterConstraints returns the OpenAPI document doc generated from file, with
+// the (openapi.v3.property) constraints of request fields also applied to the query and
+// path parameters that carry them, such as the page size limits of ListBooks.
+// protoc-gen-openapi only applies them to request bodies. Applying them again to the
+// returned document does not change it.
+func ApplyParameterConstraints(doc []byte, file protoreflect.FileDescriptor) ([]byte, error) {
+	d, err := openapiv3.ParseDocument(doc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse OpenAPI document: %w", err)
+	}
+
+	for _, path := range d.GetPaths().GetPath() {
+		item := path.GetValue()
+		for _, op := range []*openapiv3.Operation{item.GetGet(), item.GetPost(), item.GetPut(), item.GetPatch(), item.GetDelete()} {
+			if op == nil {
+				continue
+			}
+			input := operationInput(file, op.GetOperationId())
+			if input == nil {
+				continue
+			}
+			for _, param := range op.GetParameters() {
+				applyFieldConstraints(param.GetParameter(), input)
+			}
+		}
+	}
+
+	return d.YAMLValue(header)
+}
+
+// operationInput returns the request message of the RPC named by a protoc-gen-openapi
+// operation ID such as "Books_ListBooks", or nil if there is none.
+func operationInput(file protoreflect.FileDescriptor, operationID string) protoreflect.MessageDescriptor {
+	service, method, ok := strings.Cut(operationID, "_")
+	if !ok {
+		return nil
+	}
+	s := file.Services().ByName(protoreflect.Name(service))
+	if s == nil {
+		return nil
+	}
+	m := s.Methods().ByName(protoreflect.Name(method))
+	if m == nil {
+		return nil
+	}
+	return m.Input()
+}
+
+// applyFieldConstraints merges the (openapi.v3.property) option of the input field that
+// param is bound to into param's schema. 64-bit integers, which are strings in JSON
+// bodies, are documented as integers since parameters are not JSON.
+func applyFieldConstraints(param *openapiv3.Parameter, input protoreflect.MessageDescriptor) {
+	schema := param.GetSchema().GetSchema()
+	if schema == nil {
+		return
+	}
+	field := input.Fields().ByJSONName(param.GetName())
+	if field == nil {
+		field = input.Fields().ByName(protoreflect.Name(param.GetName()))
+	}
+	if field == nil {
+		return
+	}
+
+	switch field.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		schema.Type = "integer"
+		schema.Format = "int64"
+	}
+	if property, ok := proto.GetExtension(field.Options(), openapiv3.E_Property).(*openapiv3.Schema); ok && property != nil {
+		proto.Merge(schema, property)
+	}
+}
@@ -0,0 +1,19 @@
+package openapi
+
+import (
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyParameterConstraints(t *testing.T) {
+	t.Parallel()
+
+	// books/openapi.yaml is regenerated by make, which applies the constraints after
+	// protoc. Applying them again must not change it.
+	doc, err := ApplyParameterConstraints(books.OpenAPIYAML, books.File_books_books_proto)
+	require.NoError(t, err)
+	require.Equal(t, string(books.OpenAPIYAML), string(doc),
+		"books/openapi.yaml is out of date with books.proto, run make")
+}
@@ -0,0 +1,31 @@
+// Package outbox relays events about changes to books from the storage outbox to other
+// systems. Delivery is at least once: an event may be published again if the relay
+// fails after publishing it, so consumers should discard events whose ID they have
+// already seen.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event is an event about a change to a book.
+type Event struct {
+	// ID is unique to the event and identifies duplicates.
+	ID string `json:"id"`
+	// Type is "book.created", "book.updated" or "book.deleted".
+	Type string `json:"type"`
+	// Key is the ID of the book. Sinks that partition events should partition by key
+	// to keep the events about each book in order.
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+	// Payload is the book as JSON.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Sink publishes events to another system. Publish must return nil only once the event
+// has been durably accepted, and may be called again with the same event.
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+}
@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/storage"
+)
+
+// Store holds the outbox. It is implemented by *storage.MysqlStorage.
+type Store interface {
+	PublishOutbox(ctx context.Context, limit int, publish func(storage.OutboxEvent) error) (int, error)
+}
+
+// Relay publishes events from a Store's outbox to a Sink, oldest first.
+type Relay struct {
+	store     Store
+	sink      Sink
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay returns a Relay publishing events from store to sink with the poll interval
+// and batch size in conf.
+func NewRelay(conf config.OutboxConfig, store Store, sink Sink) *Relay {
+	return &Relay{
+		store:     store,
+		sink:      sink,
+		interval:  conf.PollInterval,
+		batchSize: conf.BatchSize,
+	}
+}
+
+// Run publishes events until ctx is done. When publishing fails, the event is retried
+// at the next poll, and later events wait for it so that they stay in order, including
+// on other relays sharing the outbox.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.Publish(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("failed to publish outbox events", slog.Int("published", n), slog.Any("error", err))
+		} else if n == r.batchSize {
+			// More events may be waiting, so publish them without waiting for the
+			// next poll.
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publish publishes a batch of events, and returns how many were published.
+func (r *Relay) Publish(ctx context.Context) (int, error) {
+	return r.store.PublishOutbox(ctx, r.batchSize, func(e storage.OutboxEvent) error {
+		return r.sink.Publish(ctx, Event{
+			ID:      e.ID,
+			Type:    e.Type,
+			Key:     e.Key,
+			Time:    e.Time,
+			Payload: e.Payload,
+		})
+	})
+}
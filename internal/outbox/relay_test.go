@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore is an in-memory outbox that removes events once published, like
+// storage.MysqlStorage.
+type fakeStore struct {
+	mu     sync.Mutex
+	events []storage.OutboxEvent
+	err    error
+}
+
+func (s *fakeStore) add(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		seq := uint64(len(s.events) + 1)
+		s.events = append(s.events, storage.OutboxEvent{
+			Sequence: seq,
+			ID:       fmt.Sprintf("event-%d", seq),
+			Type:     "book.created",
+			Key:      fmt.Sprintf("book-%d", seq),
+			Payload:  []byte(`{}`),
+		})
+	}
+}
+
+func (s *fakeStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func (s *fakeStore) PublishOutbox(_ context.Context, limit int, publish func(storage.OutboxEvent) error) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := 0
+	for n < limit && n < len(s.events) {
+		if err := publish(s.events[n]); err != nil {
+			s.events = s.events[n:]
+			return n, err
+		}
+		n++
+	}
+	s.events = s.events[n:]
+	return n, nil
+}
+
+// testOutboxConfig returns a config polling often, with a batch size of three.
+func testOutboxConfig() config.OutboxConfig {
+	return config.OutboxConfig{Enabled: true, PollInterval: time.Millisecond, BatchSize: 3}
+}
+
+func TestRelayPublish(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publishes one batch in order", func(t *testing.T) {
+		r := require.New(t)
+		store, sink := &fakeStore{}, &MemorySink{}
+		store.add(5)
+
+		n, err := NewRelay(testOutboxConfig(), store, sink).Publish(context.Background())
+		r.NoError(err)
+		r.Equal(3, n)
+		r.Equal(2, store.pending())
+
+		events := sink.Events()
+		r.Len(events, 3)
+		for i, e := range events {
+			r.Equal(fmt.Sprintf("event-%d", i+1), e.ID)
+			r.Equal("book.created", e.Type)
+			r.Equal(fmt.Sprintf("book-%d", i+1), e.Key)
+		}
+	})
+
+	t.Run("sink failure keeps events", func(t *testing.T) {
+		r := require.New(t)
+		store, sink := &fakeStore{}, &MemorySink{}
+		store.add(2)
+		sink.FailWith(errors.New("broker down"))
+
+		n, err := NewRelay(testOutboxConfig(), store, sink).Publish(context.Background())
+		r.ErrorContains(err, "broker down")
+		r.Zero(n)
+		r.Equal(2, store.pending())
+	})
+}
+
+func TestRelayRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("drains the outbox and retries after failures", func(t *testing.T) {
+		r := require.New(t)
+		store, sink := &fakeStore{}, &MemorySink{}
+		store.add(7)
+		sink.FailWith(errors.New("broker down"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			NewRelay(testOutboxConfig(), store, sink).Run(ctx)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		r.Equal(7, store.pending())
+		sink.FailWith(nil)
+		r.Eventually(func() bool { return store.pending() == 0 }, 5*time.Second, time.Millisecond)
+
+		cancel()
+		<-done
+		r.Len(sink.Events(), 7)
+	})
+
+	t.Run("returns when cancelled", func(t *testing.T) {
+		store := &fakeStore{err: errors.New("database down")}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			NewRelay(testOutboxConfig(), store, &MemorySink{}).Run(ctx)
+		}()
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("relay did not stop")
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends events to a file as JSON lines, syncing after each one.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens the file at path for appending, creating it if needed.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open outbox file: %w", err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Publish appends e to the file.
+func (s *FileSink) Publish(_ context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// MemorySink keeps published events in memory, for tests.
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+// Publish records e, or returns the error set with FailWith.
+func (s *MemorySink) Publish(_ context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+// FailWith makes Publish return err until it is called again with nil.
+func (s *MemorySink) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Events returns the events published so far.
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// NATSPublisher publishes a message to a NATS JetStream subject. Implementations should
+// set msgID as the Nats-Msg-Id header, which JetStream uses to discard duplicates, and
+// return once the stream has acknowledged the message.
+type NATSPublisher interface {
+	Publish(ctx context.Context, subject string, data []byte, msgID string) error
+}
+
+// NATSSink publishes events to the subject SubjectPrefix followed by the event type,
+// such as "library.book.created", with the event ID as the message ID. The message is
+// the event as JSON.
+type NATSSink struct {
+	Publisher     NATSPublisher
+	SubjectPrefix string
+}
+
+// Publish publishes e.
+func (s NATSSink) Publish(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := s.Publisher.Publish(ctx, s.SubjectPrefix+e.Type, b, e.ID); err != nil {
+		return fmt.Errorf("failed to publish event to NATS: %w", err)
+	}
+	return nil
+}
+
+// KafkaProducer produces a record to a Kafka topic. Implementations should return once
+// the record has been acknowledged by the brokers.
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+}
+
+// KafkaSink produces events to Topic keyed by book ID, so that the events about a book
+// share a partition and stay in order. The record value is the event payload, and the
+// event ID and type are sent in the "event-id" and "event-type" headers.
+type KafkaSink struct {
+	Producer KafkaProducer
+	Topic    string
+}
+
+// Publish produces e.
+func (s KafkaSink) Publish(ctx context.Context, e Event) error {
+	headers := map[string]string{
+		"event-id":   e.ID,
+		"event-type": e.Type,
+	}
+	if err := s.Producer.Produce(ctx, s.Topic, []byte(e.Key), e.Payload, headers); err != nil {
+		return fmt.Errorf("failed to produce event to Kafka: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testEvent returns an event about book b1.
+func testEvent(id string) Event {
+	return Event{
+		ID:      id,
+		Type:    "book.created",
+		Key:     "b1",
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Payload: json.RawMessage(`{"id":"b1","title":"Dune"}`),
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	sink, err := NewFileSink(path)
+	r.NoError(err)
+	r.NoError(sink.Publish(context.Background(), testEvent("e1")))
+	r.NoError(sink.Close())
+
+	// Reopening appends rather than truncating.
+	sink, err = NewFileSink(path)
+	r.NoError(err)
+	r.NoError(sink.Publish(context.Background(), testEvent("e2")))
+	r.NoError(sink.Close())
+
+	f, err := os.Open(path)
+	r.NoError(err)
+	defer f.Close()
+	var got []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		r.NoError(json.Unmarshal(scanner.Bytes(), &e))
+		got = append(got, e)
+	}
+	r.NoError(scanner.Err())
+
+	r.Len(got, 2)
+	r.Equal("e1", got[0].ID)
+	r.Equal("e2", got[1].ID)
+	r.Equal(testEvent("e1").Time, got[0].Time)
+	r.JSONEq(`{"id":"b1","title":"Dune"}`, string(got[0].Payload))
+}
+
+// fakeNATS records the last message published.
+type fakeNATS struct {
+	subject, msgID string
+	data           []byte
+	err            error
+}
+
+func (p *fakeNATS) Publish(_ context.Context, subject string, data []byte, msgID string) error {
+	p.subject, p.data, p.msgID = subject, data, msgID
+	return p.err
+}
+
+func TestNATSSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("publishes to subject for event type with event ID", func(t *testing.T) {
+		r := require.New(t)
+		publisher := &fakeNATS{}
+		sink := NATSSink{Publisher: publisher, SubjectPrefix: "library."}
+
+		r.NoError(sink.Publish(context.Background(), testEvent("e1")))
+		r.Equal("library.book.created", publisher.subject)
+		r.Equal("e1", publisher.msgID)
+		var e Event
+		r.NoError(json.Unmarshal(publisher.data, &e))
+		r.Equal("b1", e.Key)
+	})
+
+	t.Run("publish error is returned", func(t *testing.T) {
+		r := require.New(t)
+		sink := NATSSink{Publisher: &fakeNATS{err: errors.New("no responders")}}
+		r.ErrorContains(sink.Publish(context.Background(), testEvent("e1")), "no responders")
+	})
+}
+
+// fakeKafka records the last record produced.
+type fakeKafka struct {
+	topic      string
+	key, value []byte
+	headers    map[string]string
+}
+
+func (p *fakeKafka) Produce(_ context.Context, topic string, key, value []byte, headers map[string]string) error {
+	p.topic, p.key, p.value, p.headers = topic, key, value, headers
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	producer := &fakeKafka{}
+	sink := KafkaSink{Producer: producer, Topic: "books"}
+
+	r.NoError(sink.Publish(context.Background(), testEvent("e1")))
+	r.Equal("books", producer.topic)
+	r.Equal("b1", string(producer.key))
+	r.JSONEq(`{"id":"b1","title":"Dune"}`, string(producer.value))
+	r.Equal(map[string]string{"event-id": "e1", "event-type": "book.created"}, producer.headers)
+}
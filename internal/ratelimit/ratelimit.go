@@ -0,0 +1,298 @@
+// Package ratelimit limits how often each caller may call each RPC of the books server.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/serverstream"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// exemptMethodPrefixes lists RPCs that are never limited, so that orchestrator probes
+// are not rejected.
+var exemptMethodPrefixes = []string{
+	"/grpc.health.v1.Health/",
+}
+
+// idleTimeout is how long a caller's bucket or quota is kept after its last request.
+// A bucket idle this long has refilled, so dropping it does not change any decision.
+const idleTimeout = 10 * time.Minute
+
+// bucketKey identifies the token bucket of one caller for one RPC.
+type bucketKey struct {
+	caller string
+	method string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// quota counts the books a caller has written on one UTC day.
+type quota struct {
+	day    time.Time
+	writes int
+}
+
+// Limiter enforces per-caller, per-method token buckets and an optional daily write
+// quota per caller.
+type Limiter struct {
+	conf         config.RateLimitConfig
+	writeMethods map[string]bool
+	now          func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	quotas    map[string]*quota
+	lastSweep time.Time
+}
+
+// New returns a Limiter enforcing conf.
+func New(conf config.RateLimitConfig) *Limiter {
+	l := &Limiter{
+		conf:         conf,
+		writeMethods: make(map[string]bool, len(conf.WriteMethods)),
+		now:          time.Now,
+		buckets:      make(map[bucketKey]*bucket),
+		quotas:       make(map[string]*quota),
+	}
+	for _, m := range conf.WriteMethods {
+		l.writeMethods[m] = true
+	}
+	return l
+}
+
+// Allow returns nil if the caller in ctx may call fullMethod now, and consumes a token
+// and, for writes, one unit of quota. Otherwise it returns a codes.ResourceExhausted
+// status error with a RetryInfo detail saying when to retry.
+func (l *Limiter) Allow(ctx context.Context, fullMethod string) error {
+	return l.AllowN(ctx, fullMethod, 1)
+}
+
+// AllowN is like Allow, but a write consumes writes units of quota, all or none.
+func (l *Limiter) AllowN(ctx context.Context, fullMethod string, writes int) error {
+	for _, prefix := range exemptMethodPrefixes {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return nil
+		}
+	}
+
+	caller := Caller(ctx)
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	limit, ok := l.conf.Methods[fullMethod]
+	if !ok {
+		limit = l.conf.Default
+	}
+	var reservation *rate.Reservation
+	if limit.RequestsPerSecond > 0 {
+		key := bucketKey{caller: caller, method: fullMethod}
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.Burst)}
+			l.buckets[key] = b
+		}
+		b.lastSeen = now
+
+		reservation = b.limiter.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return exhausted("rate limit exceeded for "+fullMethod, delay, nil)
+		}
+	}
+
+	if err := l.chargeQuota(caller, fullMethod, now, writes); err != nil {
+		if reservation != nil {
+			reservation.CancelAt(now)
+		}
+		return err
+	}
+	return nil
+}
+
+// chargeQuota consumes writes units of the caller's daily write quota, all or none, if
+// fullMethod is a write. l.mu must be held.
+func (l *Limiter) chargeQuota(caller, fullMethod string, now time.Time, writes int) error {
+	if l.conf.DailyWriteQuota <= 0 || !l.writeMethods[fullMethod] {
+		return nil
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	q, ok := l.quotas[caller]
+	if !ok || !q.day.Equal(day) {
+		q = &quota{day: day}
+		l.quotas[caller] = q
+	}
+	if q.writes+writes > l.conf.DailyWriteQuota {
+		return exhausted("daily write quota exceeded", day.Add(24*time.Hour).Sub(now), &errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     caller,
+				Description: "daily write quota exceeded",
+			}},
+		})
+	}
+	q.writes += writes
+	return nil
+}
+
+// refundQuota returns up to writes units of the caller's quota for today if fullMethod is
+// a write. l.mu must be held.
+func (l *Limiter) refundQuota(caller, fullMethod string, now time.Time, writes int) {
+	if l.conf.DailyWriteQuota <= 0 || !l.writeMethods[fullMethod] {
+		return
+	}
+	q, ok := l.quotas[caller]
+	if !ok || !q.day.Equal(now.UTC().Truncate(24*time.Hour)) {
+		return
+	}
+	q.writes = max(q.writes-writes, 0)
+}
+
+// sweep drops buckets and quotas that have not been used for idleTimeout. It runs at
+// most once per idleTimeout.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	for caller, q := range l.quotas {
+		if q.day.Before(today) {
+			delete(l.quotas, caller)
+		}
+	}
+}
+
+// exhausted returns a codes.ResourceExhausted status error advising a retry after delay,
+// with an optional quota failure detail.
+func exhausted(msg string, delay time.Duration, failure *errdetails.QuotaFailure) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	details := []protoadapt.MessageV1{&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}}
+	if failure != nil {
+		details = append(details, failure)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Caller returns the key the caller in ctx is limited by: its authenticated principal,
+// or else its IP address. Principals are keyed by authentication method as well as
+// subject, as an API key name, a JWT subject and a certificate name are unrelated even
+// when they are equal.
+func Caller(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return "principal:" + p.Method + ":" + p.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		return "peer:" + addr
+	}
+	return "unknown"
+}
+
+// UnaryServerInterceptor rejects unary RPCs over their caller's limits. It must run
+// after authentication so that callers are limited by principal.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := l.AllowN(ctx, info.FullMethod, writes(req)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// writes returns how many books a call with req writes, and so how much write quota it
+// consumes if its method is a write.
+func writes(req any) int {
+	if batch, ok := req.(*books.BatchCreateBooksRequest); ok {
+		return max(len(batch.Requests), 1)
+	}
+	return 1
+}
+
+// StreamServerInterceptor is the streaming equivalent of UnaryServerInterceptor. A
+// stream is rate limited when it is opened, not per message. Opening a stream consumes
+// no write quota: handlers of streams that write charge it with ChargeWrites instead.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		ctx := stream.Context()
+		if err := l.AllowN(ctx, info.FullMethod, 0); err != nil {
+			return err
+		}
+		q := &streamQuota{l: l, caller: Caller(ctx), method: info.FullMethod}
+		return handler(srv, serverstream.WithContext(stream, context.WithValue(ctx, streamQuotaKey{}, q)))
+	}
+}
+
+type streamQuotaKey struct{}
+
+// streamQuota charges and refunds the daily write quota of the caller of one stream.
+type streamQuota struct {
+	l      *Limiter
+	caller string
+	method string
+}
+
+func (q *streamQuota) charge(writes int) error {
+	now := q.l.now()
+	q.l.mu.Lock()
+	defer q.l.mu.Unlock()
+	return q.l.chargeQuota(q.caller, q.method, now, writes)
+}
+
+func (q *streamQuota) refund(writes int) {
+	now := q.l.now()
+	q.l.mu.Lock()
+	defer q.l.mu.Unlock()
+	q.l.refundQuota(q.caller, q.method, now, writes)
+}
+
+// ChargeWrites consumes writes units of the daily write quota of the caller of the
+// streaming RPC in ctx, all or none. It returns a codes.ResourceExhausted status error if
+// the quota cannot cover them, and nil if the RPC is not rate limited or not a write.
+func ChargeWrites(ctx context.Context, writes int) error {
+	if q, ok := ctx.Value(streamQuotaKey{}).(*streamQuota); ok {
+		return q.charge(writes)
+	}
+	return nil
+}
+
+// RefundWrites returns writes units charged by ChargeWrites for books that were not
+// written, so that a failed write does not use up the caller's quota.
+func RefundWrites(ctx context.Context, writes int) {
+	if q, ok := ctx.Value(streamQuotaKey{}).(*streamQuota); ok {
+		q.refund(writes)
+	}
+}
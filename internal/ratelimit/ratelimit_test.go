@@ -0,0 +1,336 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestLimiter returns a Limiter enforcing conf on a fake clock starting at 10:00 UTC.
+func newTestLimiter(conf config.RateLimitConfig) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	l := New(conf)
+	l.now = clock.now
+	return l, clock
+}
+
+func principal(subject string) context.Context {
+	return auth.NewContext(context.Background(), auth.Principal{Subject: subject, Method: auth.MethodAPIKey})
+}
+
+// retryDelay returns the RetryInfo delay in err's status details.
+func retryDelay(t *testing.T, err error) time.Duration {
+	t.Helper()
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			return info.RetryDelay.AsDuration()
+		}
+	}
+	t.Fatalf("no RetryInfo in %v", err)
+	return 0
+}
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+
+	conf := config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimit{RequestsPerSecond: 1, Burst: 2},
+		Methods: map[string]config.RateLimit{
+			"/Books/ListBooks": {RequestsPerSecond: 10, Burst: 10},
+			"/Books/Unlimited": {},
+		},
+	}
+
+	t.Run("burst then rejected with retry delay", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		ctx := principal("alice")
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+
+		err := l.Allow(ctx, "/Books/CreateBook")
+		r.Equal(codes.ResourceExhausted, status.Code(err))
+		r.Equal(time.Second, retryDelay(t, err))
+	})
+
+	t.Run("tokens refill over time", func(t *testing.T) {
+		r := require.New(t)
+		l, clock := newTestLimiter(conf)
+		ctx := principal("alice")
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		r.Error(l.Allow(ctx, "/Books/CreateBook"))
+
+		clock.advance(time.Second)
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+	})
+
+	t.Run("rejected calls do not consume tokens", func(t *testing.T) {
+		r := require.New(t)
+		l, clock := newTestLimiter(conf)
+		ctx := principal("alice")
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		for i := 0; i < 5; i++ {
+			r.Error(l.Allow(ctx, "/Books/CreateBook"))
+		}
+
+		clock.advance(time.Second)
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+	})
+
+	t.Run("callers and methods have separate buckets", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.Error(l.Allow(principal("alice"), "/Books/CreateBook"))
+
+		r.NoError(l.Allow(principal("bob"), "/Books/CreateBook"))
+		r.NoError(l.Allow(principal("alice"), "/Books/ListBooks"))
+
+		// A JWT subject named like an API key is a different caller.
+		jwt := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", Method: auth.MethodJWT})
+		r.NoError(l.Allow(jwt, "/Books/CreateBook"))
+	})
+
+	t.Run("per-method limit overrides default", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		for i := 0; i < 10; i++ {
+			r.NoError(l.Allow(principal("alice"), "/Books/ListBooks"))
+		}
+		r.Error(l.Allow(principal("alice"), "/Books/ListBooks"))
+	})
+
+	t.Run("zero rate is unlimited", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		for i := 0; i < 100; i++ {
+			r.NoError(l.Allow(principal("alice"), "/Books/Unlimited"))
+		}
+	})
+
+	t.Run("health checks are exempt", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		for i := 0; i < 100; i++ {
+			r.NoError(l.Allow(context.Background(), "/grpc.health.v1.Health/Check"))
+		}
+	})
+
+	t.Run("idle buckets are dropped", func(t *testing.T) {
+		r := require.New(t)
+		l, clock := newTestLimiter(conf)
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.Len(l.buckets, 1)
+
+		clock.advance(idleTimeout)
+		r.NoError(l.Allow(principal("bob"), "/Books/CreateBook"))
+		r.Len(l.buckets, 1)
+	})
+}
+
+func TestDailyWriteQuota(t *testing.T) {
+	t.Parallel()
+
+	conf := config.RateLimitConfig{
+		Enabled:         true,
+		DailyWriteQuota: 2,
+		WriteMethods:    []string{"/Books/CreateBook"},
+	}
+
+	t.Run("exceeded until midnight UTC", func(t *testing.T) {
+		r := require.New(t)
+		l, clock := newTestLimiter(conf)
+		ctx := principal("alice")
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+
+		err := l.Allow(ctx, "/Books/CreateBook")
+		r.Equal(codes.ResourceExhausted, status.Code(err))
+		r.Equal(14*time.Hour, retryDelay(t, err))
+
+		var failure *errdetails.QuotaFailure
+		for _, d := range status.Convert(err).Details() {
+			if f, ok := d.(*errdetails.QuotaFailure); ok {
+				failure = f
+			}
+		}
+		r.NotNil(failure)
+		r.Equal("principal:api_key:alice", failure.Violations[0].Subject)
+
+		clock.advance(14 * time.Hour)
+		r.NoError(l.Allow(ctx, "/Books/CreateBook"))
+	})
+
+	t.Run("reads are not counted", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		for i := 0; i < 5; i++ {
+			r.NoError(l.Allow(principal("alice"), "/Books/ListBooks"))
+		}
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+	})
+
+	t.Run("batch consumes quota per book", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(config.RateLimitConfig{
+			Enabled:         true,
+			DailyWriteQuota: 3,
+			WriteMethods:    []string{"/Books/BatchCreateBooks"},
+		})
+		interceptor := l.UnaryServerInterceptor()
+		info := &grpc.UnaryServerInfo{FullMethod: "/Books/BatchCreateBooks"}
+		handler := func(context.Context, any) (any, error) { return nil, nil }
+		batch := func(n int) *books.BatchCreateBooksRequest {
+			return &books.BatchCreateBooksRequest{Requests: make([]*books.CreateBookRequest, n)}
+		}
+
+		_, err := interceptor(principal("alice"), batch(2), info, handler)
+		r.NoError(err)
+		_, err = interceptor(principal("alice"), batch(2), info, handler)
+		r.Equal(codes.ResourceExhausted, status.Code(err))
+		_, err = interceptor(principal("alice"), batch(1), info, handler)
+		r.NoError(err)
+	})
+
+	t.Run("quota is per caller", func(t *testing.T) {
+		r := require.New(t)
+		l, _ := newTestLimiter(conf)
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.NoError(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.Error(l.Allow(principal("alice"), "/Books/CreateBook"))
+		r.NoError(l.Allow(principal("bob"), "/Books/CreateBook"))
+	})
+}
+
+func TestCaller(t *testing.T) {
+	t.Parallel()
+
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 7), Port: 51234}
+
+	t.Run("principal takes precedence over peer", func(t *testing.T) {
+		ctx := peer.NewContext(principal("alice"), &peer.Peer{Addr: addr})
+		require.Equal(t, "principal:api_key:alice", Caller(ctx))
+	})
+
+	t.Run("same subject under different methods are different callers", func(t *testing.T) {
+		jwt := auth.NewContext(context.Background(), auth.Principal{Subject: "alice", Method: auth.MethodJWT})
+		require.Equal(t, "principal:jwt:alice", Caller(jwt))
+		require.NotEqual(t, Caller(principal("alice")), Caller(jwt))
+	})
+
+	t.Run("peer address without port", func(t *testing.T) {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+		require.Equal(t, "peer:192.0.2.7", Caller(ctx))
+	})
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	l, _ := newTestLimiter(config.RateLimitConfig{Default: config.RateLimit{RequestsPerSecond: 1, Burst: 1}})
+	interceptor := l.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/Books/ListBooks"}
+	calls := 0
+	handler := func(context.Context, any) (any, error) {
+		calls++
+		return nil, nil
+	}
+
+	_, err := interceptor(principal("alice"), nil, info, handler)
+	r.NoError(err)
+	_, err = interceptor(principal("alice"), nil, info, handler)
+	r.Equal(codes.ResourceExhausted, status.Code(err))
+	r.Equal(1, calls)
+}
+
+// fakeServerStream is a grpc.ServerStream with only a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	l, _ := newTestLimiter(config.RateLimitConfig{
+		Enabled:         true,
+		DailyWriteQuota: 3,
+		WriteMethods:    []string{"/Books/ImportBooks"},
+	})
+	interceptor := l.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/Books/ImportBooks"}
+
+	// Opening streams consumes no quota, and writes are charged as the handler makes them.
+	var errs []error
+	handler := func(_ any, stream grpc.ServerStream) error {
+		for _, writes := range []int{2, 2, 1} {
+			errs = append(errs, ChargeWrites(stream.Context(), writes))
+		}
+		return nil
+	}
+	r.NoError(interceptor(nil, &fakeServerStream{ctx: principal("alice")}, info, handler))
+	r.NoError(errs[0])
+	r.Equal(codes.ResourceExhausted, status.Code(errs[1]))
+	r.NoError(errs[2])
+
+	r.NoError(ChargeWrites(context.Background(), 100))
+}
+
+func TestRefundWrites(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	l, _ := newTestLimiter(config.RateLimitConfig{
+		Enabled:         true,
+		DailyWriteQuota: 3,
+		WriteMethods:    []string{"/Books/ImportBooks"},
+	})
+	info := &grpc.StreamServerInfo{FullMethod: "/Books/ImportBooks"}
+
+	// Refunded writes can be charged again, and refunds never raise the quota.
+	var errs []error
+	handler := func(_ any, stream grpc.ServerStream) error {
+		ctx := stream.Context()
+		errs = append(errs, ChargeWrites(ctx, 3), ChargeWrites(ctx, 1))
+		RefundWrites(ctx, 2)
+		errs = append(errs, ChargeWrites(ctx, 2))
+		RefundWrites(ctx, 10)
+		errs = append(errs, ChargeWrites(ctx, 3), ChargeWrites(ctx, 1))
+		return nil
+	}
+	r.NoError(l.StreamServerInterceptor()(nil, &fakeServerStream{ctx: principal("alice")}, info, handler))
+	r.NoError(errs[0])
+	r.Equal(codes.ResourceExhausted, status.Code(errs[1]))
+	r.NoError(errs[2])
+	r.NoError(errs[3])
+	r.Equal(codes.ResourceExhausted, status.Code(errs[4]))
+
+	RefundWrites(context.Background(), 1)
+}
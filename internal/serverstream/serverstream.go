@@ -0,0 +1,24 @@
+// Package serverstream wraps grpc.ServerStream for stream interceptors.
+package serverstream
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// WithContext returns stream with its context replaced by ctx, so that a stream
+// interceptor can pass values or a derived context on to the handler.
+func WithContext(stream grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &contextStream{ServerStream: stream, ctx: ctx}
+}
+
+// contextStream overrides the context of a grpc.ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,38 @@
+package serverstream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type ctxKey struct{}
+
+// fakeServerStream records the headers set on it.
+type fakeServerStream struct {
+	grpc.ServerStream
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestWithContext(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	inner := &fakeServerStream{}
+	stream := WithContext(inner, context.WithValue(context.Background(), ctxKey{}, "value"))
+	r.Equal("value", stream.Context().Value(ctxKey{}))
+
+	// Other methods are passed to the wrapped stream.
+	r.NoError(stream.SetHeader(metadata.Pairs("k", "v")))
+	r.Equal([]string{"v"}, inner.header.Get("k"))
+}
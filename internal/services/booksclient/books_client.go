@@ -1,24 +1,62 @@
 package booksclient
 
 import (
-	"log"
+	"fmt"
 
 	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/tlsconfig"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 // MustNewBooksClient creates and returns a new books client and its client connection,
-// or panics if an error is encountered. It is recommended that any client connection is
-// subsequently closed with a deferred *grpc.ClientConn.Close().
-func MustNewBooksClient(address string) (books.BooksClient, *grpc.ClientConn) {
+// or panics if an error is encountered. The configured API key or bearer token is sent
+// with every call, and trace context in each call's context is propagated to the
+// server. It is recommended that any client connection is subsequently closed with a
+// deferred *grpc.ClientConn.Close().
+func MustNewBooksClient(conf config.ClientConfig) (books.BooksClient, *grpc.ClientConn) {
+	creds, err := transportCredentials(conf.TLS)
+	if err != nil {
+		panic(fmt.Errorf("failed to load books gRPC client credentials: %w", err))
+	}
+
 	// Connect to server
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+	switch {
+	case conf.BearerToken != "":
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.BearerTokenCredentials{Token: conf.BearerToken}))
+	case conf.APIKey != "":
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.APIKeyCredentials{Key: conf.APIKey}))
+	}
+
+	conn, err := grpc.Dial(conf.Address, opts...)
 	if err != nil {
-		log.Panicf("failed to create books gRPC client: %v", err)
+		panic(fmt.Errorf("failed to create books gRPC client: %w", err))
 	}
 
 	// Create client
 	client := books.NewBooksClient(conn)
 	return client, conn
 }
+
+// transportCredentials returns TLS credentials verifying the server against the CA file,
+// or the system roots if none is set, and presenting the client certificate if set.
+// Returns insecure credentials if TLS is disabled.
+func transportCredentials(conf config.TLSConfig) (credentials.TransportCredentials, error) {
+	if !conf.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConf, err := tlsconfig.Client(conf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load client TLS credentials: %w", err)
+	}
+	return credentials.NewTLS(tlsConf), nil
+}
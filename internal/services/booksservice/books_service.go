@@ -2,71 +2,36 @@ package booksservice
 
 import (
 	"context"
-	"log"
-	"net"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 
 	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/logging"
 	"github.com/celestebrant/library-of-books/storage"
-	"google.golang.org/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// MustNewBooksServer creates a new BooksServer in a goroutine, and panics if setup fails.
-// Returns the books gRPC server and its network listener, and subsequence closures
-// should be deferred with *grpc.Server.Stop() and net.Listener.Close().
-func MustNewBooksServer(address string, wg *sync.WaitGroup) (*grpc.Server, net.Listener) {
-	// Create a network listener
-	lis, err := net.Listen("tcp", address)
-	if err != nil {
-		log.Panicf("failed to listen: %v", err)
-	}
-
-	// Create a new database connection that the books server can use to write to the db
-	dbConn, err := storage.NewMysqlStorage(storage.MysqlConfig{
-		Username: "user1",
-		Password: "password1",
-		DBName:   "library",
-		Port:     3306,
-		Host:     "localhost", // this code will execute in machine, not container
-	})
-	if err != nil {
-		log.Panic(err)
-	}
-
-	// Create a new gRPC server registered with booksServer
-	grpcServer := grpc.NewServer()
-	books.RegisterBooksServer(grpcServer, &BooksServer{
-		MysqlStorage: &dbConn,
-	})
-	log.Printf("gRPC server books listening on %s", address)
-
-	// Connect the new server to the network listener in a goroutine
-	go func() {
-		defer wg.Done()
-		err = grpcServer.Serve(lis)
-		if err != nil {
-			log.Panicf("failed to serve: %v", err)
-		}
-	}()
-
-	return grpcServer, lis
-}
-
-// StopBooksServer stops server, closes lis and waits until wg is zero.
-func StopBooksServer(server *grpc.Server, lis net.Listener, wg *sync.WaitGroup) {
-	server.Stop()
-	lis.Close()
-	wg.Wait()
-}
-
 // BooksServer represents the books service and implements storage.MysqlStorage
 // to enable database connections.
 type BooksServer struct {
 	books.UnimplementedBooksServer
 	*storage.MysqlStorage
+
+	// watchPollInterval is how often WatchBooks polls the change log, and closing
+	// watchDone, once, ends every WatchBooks stream.
+	watchPollInterval time.Duration
+	watchDone         chan struct{}
+	stopWatchesOnce   sync.Once
+
+	// importChunkSize is how many records ImportBooks commits at a time.
+	importChunkSize int
 }
 
 // CreateBook processes a CreateBookRequest to validate the input, create a new Book record from the request,
@@ -83,6 +48,7 @@ func (s *BooksServer) CreateBook(
 
 	book := storage.NewBookFromRequest(req)
 	if err := s.MysqlStorage.CreateBook(ctx, book); err != nil {
+		logging.FromContext(ctx).Error("failed to create book", slog.String("book_id", book.Id), slog.Any("error", err))
 		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
@@ -94,6 +60,64 @@ func (s *BooksServer) CreateBook(
 	}}, nil
 }
 
+// GetBook retrieves a single book by its ID.
+//
+// Returns a NotFound error if no book has the ID, or an error if the request is invalid
+// or a storage error occurs.
+func (s *BooksServer) GetBook(
+	ctx context.Context, req *books.GetBookRequest,
+) (*books.GetBookResponse, error) {
+	if err := ValidateGetBookRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	book, err := s.MysqlStorage.GetBook(ctx, req.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "book %s not found", req.Id)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get book", slog.String("book_id", req.Id), slog.Any("error", err))
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return &books.GetBookResponse{Book: &books.Book{
+		Id:           book.Id,
+		Title:        book.Title,
+		Author:       book.Author,
+		CreationTime: timestamppb.New(book.CreationTime),
+	}}, nil
+}
+
+// BatchGetBooks retrieves the books with the requested IDs with a single storage query.
+// The books found are returned in request order, and the IDs without a book are listed
+// as missing rather than failing the call.
+//
+// Returns an error if the request is invalid or a storage error occurs.
+func (s *BooksServer) BatchGetBooks(
+	ctx context.Context, req *books.BatchGetBooksRequest,
+) (*books.BatchGetBooksResponse, error) {
+	if err := ValidateBatchGetBooksRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	found, err := s.MysqlStorage.GetBooks(ctx, req.Ids)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get books", slog.Int("ids", len(req.Ids)), slog.Any("error", err))
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	res := &books.BatchGetBooksResponse{}
+	for _, id := range req.Ids {
+		book, ok := found[id]
+		if !ok {
+			res.MissingIds = append(res.MissingIds, id)
+			continue
+		}
+		res.Books = append(res.Books, bookProto(&book))
+	}
+	return res, nil
+}
+
 // ListBooks retrieves a paginated list of books based on author and title filters.
 // It validates the request, fetches data from storage, and handles pagination via pageSize and nextPageToken.
 //
@@ -107,8 +131,134 @@ func (s *BooksServer) ListBooks(
 
 	res, err := s.MysqlStorage.ListBooks(ctx, req.Author, req.Title, req.PageSize, req.PageToken)
 	if err != nil {
+		logging.FromContext(ctx).Error("failed to list books", slog.Any("error", err))
 		return nil, status.Error(codes.FailedPrecondition, err.Error())
 	}
 
 	return res, nil
 }
+
+// BatchCreateBooks validates each of the requests with ValidateCreateBookRequest and
+// creates the books in a single transaction. The response has a result for each request,
+// in request order, with the created book or the status of the failure. Requests for a
+// book ID that exists, or that an earlier request in the batch uses, fail with
+// AlreadyExists.
+//
+// Unless partial success is allowed, no book is created if any request fails, and the
+// call fails with the first failure's code. Invalid requests are then listed in a
+// BadRequest error detail. Returns an error if the batch is empty or too large, or a
+// storage error occurs, which is AlreadyExists if books of the batch kept being created
+// concurrently.
+func (s *BooksServer) BatchCreateBooks(
+	ctx context.Context, req *books.BatchCreateBooksRequest,
+) (*books.BatchCreateBooksResponse, error) {
+	if err := ValidateBatchCreateBooksRequest(req); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	results := make([]*books.BatchCreateBookResult, len(req.Requests))
+	var violations []*errdetails.BadRequest_FieldViolation
+	var created []*storage.Book
+	var createdIndex []int
+	requestByID := make(map[string]int, len(req.Requests))
+	for i, r := range req.Requests {
+		if err := ValidateCreateBookRequest(r); err != nil {
+			results[i] = &books.BatchCreateBookResult{Status: status.New(codes.InvalidArgument, err.Error()).Proto()}
+			var verr *ValidationError
+			if errors.As(err, &verr) {
+				violations = append(violations, &errdetails.BadRequest_FieldViolation{
+					Field:       fmt.Sprintf("requests[%d].%s", i, verr.Field),
+					Description: verr.Message,
+				})
+			}
+			continue
+		}
+
+		book := storage.NewBookFromRequest(r)
+		if j, ok := requestByID[book.Id]; ok {
+			results[i] = &books.BatchCreateBookResult{
+				Status: status.Newf(codes.AlreadyExists, "book %s is also created by request %d", book.Id, j).Proto(),
+			}
+			continue
+		}
+		requestByID[book.Id] = i
+		created = append(created, book)
+		createdIndex = append(createdIndex, i)
+	}
+
+	if !req.AllowPartialSuccess {
+		if err := firstFailure(results, violations); err != nil {
+			return nil, err
+		}
+	}
+
+	var existing []string
+	if len(created) > 0 {
+		var err error
+		existing, err = s.MysqlStorage.CreateBooks(ctx, created, req.AllowPartialSuccess)
+		if errors.Is(err, storage.ErrBookExists) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to create books", slog.Int("books", len(created)), slog.Any("error", err))
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+	for j, book := range created {
+		if exists[book.Id] {
+			results[createdIndex[j]] = &books.BatchCreateBookResult{
+				Status: status.Newf(codes.AlreadyExists, "book %s already exists", book.Id).Proto(),
+			}
+		}
+	}
+	if !req.AllowPartialSuccess {
+		if err := firstFailure(results, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for j, book := range created {
+		i := createdIndex[j]
+		if results[i] != nil {
+			continue
+		}
+		results[i] = &books.BatchCreateBookResult{
+			Book:   bookProto(book),
+			Status: status.New(codes.OK, "").Proto(),
+		}
+	}
+	return &books.BatchCreateBooksResponse{Results: results}, nil
+}
+
+// firstFailure returns the status of the first failed result as an error prefixed with
+// its request index, with violations attached as a BadRequest detail if any. Returns nil
+// if no result has failed yet.
+func firstFailure(results []*books.BatchCreateBookResult, violations []*errdetails.BadRequest_FieldViolation) error {
+	for i, r := range results {
+		if r == nil {
+			continue
+		}
+		st := status.Newf(codes.Code(r.Status.Code), "requests[%d]: %s", i, r.Status.Message)
+		if len(violations) > 0 {
+			if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+				st = withDetails
+			}
+		}
+		return st.Err()
+	}
+	return nil
+}
+
+// bookProto returns b as a books.Book.
+func bookProto(b *storage.Book) *books.Book {
+	return &books.Book{
+		Id:           b.Id,
+		Title:        b.Title,
+		Author:       b.Author,
+		CreationTime: timestamppb.New(b.CreationTime),
+	}
+}
@@ -0,0 +1,36 @@
+package booksservice
+
+import (
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFirstFailure(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no failures", func(t *testing.T) {
+		r := require.New(t)
+		r.NoError(firstFailure(make([]*books.BatchCreateBookResult, 3), nil))
+	})
+
+	t.Run("first failure is returned with its index and violations", func(t *testing.T) {
+		r := require.New(t)
+		results := []*books.BatchCreateBookResult{
+			nil,
+			{Status: status.New(codes.AlreadyExists, "book b1 already exists").Proto()},
+			{Status: status.New(codes.InvalidArgument, "bad title").Proto()},
+		}
+		violations := []*errdetails.BadRequest_FieldViolation{{Field: "requests[2].title", Description: "must not be empty"}}
+
+		st := status.Convert(firstFailure(results, violations))
+		r.Equal(codes.AlreadyExists, st.Code())
+		r.Equal("requests[1]: book b1 already exists", st.Message())
+		r.Len(st.Details(), 1)
+		r.Equal("requests[2].title", st.Details()[0].(*errdetails.BadRequest).FieldViolations[0].Field)
+	})
+}
@@ -0,0 +1,53 @@
+package booksservice
+
+import (
+	"net/http"
+	"sync"
+
+	books "github.com/celestebrant/library-of-books/books"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// DescriptorSetPath is where the admin server serves the binary FileDescriptorSet of the
+// gRPC services, for tools such as grpcurl -protoset when reflection is disabled.
+const DescriptorSetPath = "/descriptors.protoset"
+
+// descriptorSet returns a FileDescriptorSet of the files declaring the services that
+// the server registers, and every file they import, built from the descriptors compiled
+// into the binary. Dependencies precede the files that import them.
+var descriptorSet = sync.OnceValues(func() ([]byte, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := map[string]bool{}
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(books.File_books_books_proto)
+	add(healthpb.File_grpc_health_v1_health_proto)
+
+	return proto.Marshal(set)
+})
+
+// serveDescriptorSet writes the descriptor set.
+func serveDescriptorSet(w http.ResponseWriter, _ *http.Request) {
+	b, err := descriptorSet()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="books.protoset"`)
+	w.Write(b)
+}
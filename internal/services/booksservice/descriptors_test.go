@@ -0,0 +1,36 @@
+package booksservice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestServeDescriptorSet(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	rec := httptest.NewRecorder()
+	serveDescriptorSet(rec, httptest.NewRequest(http.MethodGet, DescriptorSetPath, nil))
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("application/octet-stream", rec.Header().Get("Content-Type"))
+
+	var set descriptorpb.FileDescriptorSet
+	r.NoError(proto.Unmarshal(rec.Body.Bytes(), &set))
+
+	// The set must be self-contained for tools to load it.
+	files, err := protodesc.NewFiles(&set)
+	r.NoError(err)
+	for _, name := range []protoreflect.FullName{"Books", "grpc.health.v1.Health"} {
+		_, err := files.FindDescriptorByName(name)
+		r.NoError(err, string(name))
+	}
+	_, err = files.FindDescriptorByName("Books.GetBook")
+	r.NoError(err)
+}
@@ -1,6 +1,13 @@
 package booksservice
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrShutdownTimeout is returned by Server.Shutdown when in-flight RPCs did not finish
+// within the shutdown timeout and the server was stopped forcefully.
+var ErrShutdownTimeout = errors.New("shutdown timeout exceeded, server stopped forcefully")
 
 type ValidationError struct {
 	Field   string `json:"field"`
@@ -0,0 +1,186 @@
+package booksservice
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/serverstream"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// gatewayBufferSize is the size of the in-memory connection buffer between the REST
+// gateway and the gRPC server it calls.
+const gatewayBufferSize = 1 << 20
+
+// forwardedForHeader is set by the gateway to the HTTP client's address.
+const forwardedForHeader = "x-forwarded-for"
+
+// clientCertHeader is set by the gateway to the base64-encoded DER of the client
+// certificate verified on the HTTP connection, if any. HTTP clients cannot set it.
+const clientCertHeader = "x-gateway-client-cert"
+
+// dialGateway returns a client connection to lis, over which the REST gateway calls the
+// in-process gRPC server. Transport security does not apply to this connection; the
+// client certificate verified by the gateway is forwarded in clientCertHeader instead.
+func dialGateway(lis *bufconn.Listener) (*grpc.ClientConn, error) {
+	return grpc.Dial("passthrough:///gateway",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// newGatewayHandler returns an HTTP handler serving the Books RPCs as REST/JSON by
+// calling them on conn, at the routes given by the google.api.http options in
+// books.proto, such as GET /v1/books for ListBooks and GET /v1/books:watch for
+// WatchBooks. It also serves the OpenAPI v3 document describing them at OpenAPIPath.
+// Errors are returned as JSON statuses with the HTTP status matching the gRPC code.
+func newGatewayHandler(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(gatewayIncomingHeader),
+		runtime.WithOutgoingHeaderMatcher(gatewayOutgoingHeader),
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithMetadata(gatewayClientCert),
+	)
+	if err := books.RegisterBooksHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+	if err := mux.HandlePath(http.MethodGet, OpenAPIPath, serveOpenAPI); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// gatewayIncomingHeader forwards the API key and request ID headers as gRPC metadata of
+// the same name, in addition to the headers forwarded by default such as Authorization.
+// Headers that would be forwarded as clientCertHeader are dropped.
+func gatewayIncomingHeader(key string) (string, bool) {
+	switch k := strings.ToLower(key); k {
+	case auth.APIKeyHeader, logging.RequestIDHeader:
+		return k, true
+	}
+	k, ok := runtime.DefaultHeaderMatcher(key)
+	if strings.EqualFold(k, clientCertHeader) {
+		return "", false
+	}
+	return k, ok
+}
+
+// gatewayClientCert returns the client certificate verified on the HTTP connection of r,
+// if any, as clientCertHeader metadata.
+func gatewayClientCert(_ context.Context, r *http.Request) metadata.MD {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return metadata.Pairs(clientCertHeader, base64.StdEncoding.EncodeToString(r.TLS.VerifiedChains[0][0].Raw))
+}
+
+// gatewayOutgoingHeader returns the request ID response metadata as the X-Request-Id
+// header, and other metadata with the default Grpc-Metadata- prefix.
+func gatewayOutgoingHeader(key string) (string, bool) {
+	if key == logging.RequestIDHeader {
+		return http.CanonicalHeaderKey(key), true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// gatewayErrorHandler writes err with runtime.DefaultHTTPErrorHandler, which maps gRPC
+// codes to HTTP statuses, for example ResourceExhausted to 429 Too Many Requests. If
+// the status advises when to retry, a Retry-After header is set as well.
+func gatewayErrorHandler(
+	ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error,
+) {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			seconds := math.Ceil(info.RetryDelay.AsDuration().Seconds())
+			w.Header().Set("Retry-After", strconv.Itoa(int(seconds)))
+		}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+// gatewayPeer replaces the peer of ctx, which is the in-memory connection from the
+// gateway, with the HTTP client: its address, which the gateway appends as the last
+// x-forwarded-for value, and the client certificate the gateway verified, which is
+// presented as if verified by this server. This is only trusted on the gateway's own
+// gRPC server.
+func gatewayPeer(ctx context.Context) context.Context {
+	client := &peer.Peer{}
+	if p, ok := peer.FromContext(ctx); ok {
+		*client = *p
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, forwardedForHeader); len(values) > 0 {
+		hops := strings.Split(values[len(values)-1], ",")
+		if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+			client.Addr = &net.TCPAddr{IP: ip}
+		}
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, clientCertHeader); len(values) == 1 {
+		if cert := parseClientCert(values[0]); cert != nil {
+			client.AuthInfo = credentials.TLSInfo{
+				State:          tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+				CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity},
+			}
+		}
+	}
+	return peer.NewContext(ctx, client)
+}
+
+// parseClientCert returns the certificate encoded in a clientCertHeader value, or nil if
+// it cannot be decoded.
+func parseClientCert(value string) *x509.Certificate {
+	der, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// gatewayPeerUnaryInterceptor applies gatewayPeer to unary RPCs from the gateway.
+func gatewayPeerUnaryInterceptor(
+	ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (any, error) {
+	return handler(gatewayPeer(ctx), req)
+}
+
+// gatewayPeerStreamInterceptor applies gatewayPeer to streaming RPCs from the gateway.
+func gatewayPeerStreamInterceptor(
+	srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	return handler(srv, serverstream.WithContext(stream, gatewayPeer(stream.Context())))
+}
+
+// newGatewayServer returns an HTTP server for handler, serving TLS with tlsConf if it is
+// not nil.
+func newGatewayServer(address string, handler http.Handler, tlsConf *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
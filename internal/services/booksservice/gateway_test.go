@@ -0,0 +1,334 @@
+package booksservice
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeBooksServer records the requests it receives and returns err if set.
+type fakeBooksServer struct {
+	books.UnimplementedBooksServer
+
+	mu       sync.Mutex
+	err      error
+	lastReq  any
+	lastMD   metadata.MD
+	lastPeer string
+	lastCert string
+}
+
+func (s *fakeBooksServer) record(ctx context.Context, req any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastReq = req
+	s.lastMD, _ = metadata.FromIncomingContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok {
+		s.lastPeer = p.Addr.String()
+	}
+	s.lastCert = ""
+	if cert, ok := auth.ClientCertificate(ctx); ok {
+		s.lastCert = auth.CertificateIdentity(cert)
+	}
+	return s.err
+}
+
+func (s *fakeBooksServer) CreateBook(ctx context.Context, req *books.CreateBookRequest) (*books.CreateBookResponse, error) {
+	if err := s.record(ctx, req); err != nil {
+		return nil, err
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", "req-123"))
+	return &books.CreateBookResponse{Book: req.Book}, nil
+}
+
+func (s *fakeBooksServer) ListBooks(ctx context.Context, req *books.ListBooksRequest) (*books.ListBooksResponse, error) {
+	if err := s.record(ctx, req); err != nil {
+		return nil, err
+	}
+	return &books.ListBooksResponse{
+		Books:         []*books.Book{{Id: "b1", Title: "Dune", Author: "Frank Herbert"}},
+		NextPageToken: "b1",
+	}, nil
+}
+
+func (s *fakeBooksServer) GetBook(ctx context.Context, req *books.GetBookRequest) (*books.GetBookResponse, error) {
+	if err := s.record(ctx, req); err != nil {
+		return nil, err
+	}
+	return &books.GetBookResponse{Book: &books.Book{Id: req.Id, Title: "Dune", Author: "Frank Herbert"}}, nil
+}
+
+func (s *fakeBooksServer) WatchBooks(req *books.WatchBooksRequest, stream books.Books_WatchBooksServer) error {
+	if err := s.record(stream.Context(), req); err != nil {
+		return err
+	}
+	stream.SendHeader(metadata.Pairs(WatchCursorHeader, "c1"))
+	return stream.Send(&books.WatchBooksResponse{
+		Type:   books.WatchBooksResponse_CREATED,
+		Book:   &books.Book{Id: "b2", Title: "Dune", Author: "Frank Herbert"},
+		Cursor: "c2",
+	})
+}
+
+// newTestGateway serves the gateway for fake over HTTP and returns its URL.
+func newTestGateway(t *testing.T, fake *fakeBooksServer) string {
+	t.Helper()
+	httpServer := httptest.NewServer(newTestGatewayHandler(t, fake))
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL
+}
+
+// newTestGatewayHandler returns the gateway handler for fake.
+func newTestGatewayHandler(t *testing.T, fake *fakeBooksServer) http.Handler {
+	t.Helper()
+	lis := bufconn.Listen(gatewayBufferSize)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(gatewayPeerUnaryInterceptor))
+	books.RegisterBooksServer(s, fake)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := dialGateway(lis)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	handler, err := newGatewayHandler(context.Background(), conn)
+	require.NoError(t, err)
+	return handler
+}
+
+// newTestCert returns a certificate for commonName and its key, signed by parent and
+// parentKey, or self-signed as a CA if parent is nil.
+func newTestCert(t *testing.T, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestGateway(t *testing.T) {
+	t.Parallel()
+
+	t.Run("POST /v1/books calls CreateBook", func(t *testing.T) {
+		r := require.New(t)
+		fake := &fakeBooksServer{}
+		url := newTestGateway(t, fake)
+
+		req, err := http.NewRequest(http.MethodPost, url+"/v1/books",
+			strings.NewReader(`{"request_id":"r1","book":{"title":"Dune","author":"Frank Herbert"}}`))
+		r.NoError(err)
+		req.Header.Set("X-Api-Key", "secret")
+		req.Header.Set("Authorization", "Bearer token")
+		res, err := http.DefaultClient.Do(req)
+		r.NoError(err)
+		defer res.Body.Close()
+
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Equal("req-123", res.Header.Get("X-Request-Id"))
+		r.Equal("r1", fake.lastReq.(*books.CreateBookRequest).RequestId)
+		r.Equal("Dune", fake.lastReq.(*books.CreateBookRequest).Book.Title)
+		r.Equal([]string{"secret"}, fake.lastMD.Get("x-api-key"))
+		r.Equal([]string{"Bearer token"}, fake.lastMD.Get("authorization"))
+		r.Contains(fake.lastPeer, "127.0.0.1")
+	})
+
+	t.Run("GET /v1/books maps query parameters to ListBooks", func(t *testing.T) {
+		r := require.New(t)
+		fake := &fakeBooksServer{}
+		url := newTestGateway(t, fake)
+
+		res, err := http.Get(url + "/v1/books?author=Frank+Herbert&title=Dune&page_size=5&page_token=abc")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+
+		got := fake.lastReq.(*books.ListBooksRequest)
+		r.Equal("Frank Herbert", got.Author)
+		r.Equal("Dune", got.Title)
+		r.Equal(int64(5), got.PageSize)
+		r.Equal("abc", got.PageToken)
+
+		var body struct {
+			Books         []map[string]any `json:"books"`
+			NextPageToken string           `json:"nextPageToken"`
+		}
+		r.NoError(json.NewDecoder(res.Body).Decode(&body))
+		r.Len(body.Books, 1)
+		r.Equal("b1", body.NextPageToken)
+	})
+
+	t.Run("GET /v1/books/{id} calls GetBook", func(t *testing.T) {
+		r := require.New(t)
+		fake := &fakeBooksServer{}
+		url := newTestGateway(t, fake)
+
+		res, err := http.Get(url + "/v1/books/b1")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Equal("b1", fake.lastReq.(*books.GetBookRequest).Id)
+	})
+
+	t.Run("GET /v1/books:watch streams WatchBooks changes", func(t *testing.T) {
+		r := require.New(t)
+		fake := &fakeBooksServer{}
+		url := newTestGateway(t, fake)
+
+		res, err := http.Get(url + "/v1/books:watch?cursor=c0")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Equal("c1", res.Header.Get("Grpc-Metadata-X-Watch-Cursor"))
+		r.Equal("c0", fake.lastReq.(*books.WatchBooksRequest).Cursor)
+
+		var line struct {
+			Result struct {
+				Type   string `json:"type"`
+				Cursor string `json:"cursor"`
+			} `json:"result"`
+		}
+		r.NoError(json.NewDecoder(res.Body).Decode(&line))
+		r.Equal("CREATED", line.Result.Type)
+		r.Equal("c2", line.Result.Cursor)
+	})
+
+	t.Run("malformed query parameter is a bad request", func(t *testing.T) {
+		r := require.New(t)
+		url := newTestGateway(t, &fakeBooksServer{})
+		res, err := http.Get(url + "/v1/books?page_size=lots")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusBadRequest, res.StatusCode)
+	})
+
+	statuses := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.Internal, http.StatusInternalServerError},
+	}
+	for _, tc := range statuses {
+		t.Run(tc.code.String()+" maps to HTTP status", func(t *testing.T) {
+			r := require.New(t)
+			url := newTestGateway(t, &fakeBooksServer{err: status.Error(tc.code, "failed")})
+			res, err := http.Get(url + "/v1/books/b1")
+			r.NoError(err)
+			defer res.Body.Close()
+			r.Equal(tc.want, res.StatusCode)
+
+			b, err := io.ReadAll(res.Body)
+			r.NoError(err)
+			r.Contains(string(b), `"message":"failed"`)
+		})
+	}
+
+	t.Run("retry info sets Retry-After", func(t *testing.T) {
+		r := require.New(t)
+		st, err := status.New(codes.ResourceExhausted, "slow down").
+			WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)})
+		r.NoError(err)
+		url := newTestGateway(t, &fakeBooksServer{err: st.Err()})
+
+		res, err := http.Get(url + "/v1/books?page_size=1")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusTooManyRequests, res.StatusCode)
+		r.Equal("2", res.Header.Get("Retry-After"))
+	})
+}
+
+func TestGatewayClientCertificate(t *testing.T) {
+	t.Parallel()
+	ca, caKey := newTestCert(t, "test CA", nil, nil)
+	clientCert, clientKey := newTestCert(t, "alice", ca, caKey)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	fake := &fakeBooksServer{}
+	httpServer := httptest.NewUnstartedServer(newTestGatewayHandler(t, fake))
+	httpServer.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: clientCAs}
+	httpServer.StartTLS()
+	t.Cleanup(httpServer.Close)
+
+	// newClient returns an HTTP client trusting the gateway and presenting certs.
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		roots := x509.NewCertPool()
+		roots.AddCert(httpServer.Certificate())
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+	}
+
+	t.Run("verified certificate is forwarded as the peer's", func(t *testing.T) {
+		r := require.New(t)
+		client := newClient(tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey})
+		res, err := client.Get(httpServer.URL + "/v1/books/b1")
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Equal("alice", fake.lastCert)
+	})
+
+	t.Run("certificate header sent by the client is dropped", func(t *testing.T) {
+		r := require.New(t)
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/v1/books/b1", nil)
+		r.NoError(err)
+		req.Header.Set("Grpc-Metadata-"+clientCertHeader, base64.StdEncoding.EncodeToString(clientCert.Raw))
+		res, err := newClient().Do(req)
+		r.NoError(err)
+		defer res.Body.Close()
+		r.Equal(http.StatusOK, res.StatusCode)
+		r.Empty(fake.lastCert)
+		r.Empty(fake.lastMD.Get(clientCertHeader))
+	})
+}
@@ -0,0 +1,79 @@
+package booksservice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Health check service names. Probe LivenessService to decide whether to restart the
+// process, and ReadinessService to decide whether to route traffic to it.
+const (
+	// LivenessService reports SERVING while the process is running, regardless of
+	// whether its dependencies are reachable. The empty service name reports the same.
+	LivenessService = "liveness"
+
+	// ReadinessService reports SERVING only while the database is reachable. The Books
+	// service name reports the same.
+	ReadinessService = "readiness"
+)
+
+// pinger is implemented by storage that can report whether it is reachable.
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// newHealthServer returns a health server reporting the process as live and ready.
+func newHealthServer() *health.Server {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(LivenessService, healthpb.HealthCheckResponse_SERVING)
+	setReadiness(healthServer, healthpb.HealthCheckResponse_SERVING)
+	return healthServer
+}
+
+// setReadiness sets the status of every service that depends on the database.
+func setReadiness(healthServer *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	healthServer.SetServingStatus(ReadinessService, status)
+	healthServer.SetServingStatus(books.Books_ServiceDesc.ServiceName, status)
+}
+
+// monitorDatabase pings db every interval until ctx is done, marking the readiness
+// services NOT_SERVING while a ping fails or takes longer than timeout. Liveness is
+// left untouched so that losing the database does not get the process restarted.
+func monitorDatabase(
+	ctx context.Context, healthServer *health.Server, db pinger, interval, timeout time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	serving := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := db.Ping(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err != nil && serving:
+			slog.Warn("database unreachable, marking books service NOT_SERVING", slog.Any("error", err))
+			setReadiness(healthServer, healthpb.HealthCheckResponse_NOT_SERVING)
+		case err == nil && !serving:
+			slog.Info("database reachable again, marking books service SERVING")
+			setReadiness(healthServer, healthpb.HealthCheckResponse_SERVING)
+		}
+		serving = err == nil
+	}
+}
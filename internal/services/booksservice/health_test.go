@@ -0,0 +1,66 @@
+package booksservice
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakePinger fails its pings while down is set.
+type fakePinger struct {
+	down atomic.Bool
+}
+
+func (p *fakePinger) Ping(context.Context) error {
+	if p.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// servingStatus returns the current status of service on the health server.
+func servingStatus(t *testing.T, s healthpb.HealthServer, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	res, err := s.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return res.Status
+}
+
+func TestMonitorDatabase(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	healthServer := newHealthServer()
+	db := &fakePinger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		monitorDatabase(ctx, healthServer, db, time.Millisecond, time.Second)
+	}()
+
+	// Losing the database marks readiness NOT_SERVING but leaves liveness untouched.
+	db.down.Store(true)
+	r.Eventually(func() bool {
+		return servingStatus(t, healthServer, ReadinessService) == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond)
+	r.Equal(healthpb.HealthCheckResponse_NOT_SERVING, servingStatus(t, healthServer, books.Books_ServiceDesc.ServiceName))
+	r.Equal(healthpb.HealthCheckResponse_SERVING, servingStatus(t, healthServer, LivenessService))
+	r.Equal(healthpb.HealthCheckResponse_SERVING, servingStatus(t, healthServer, ""))
+
+	// Recovering the database marks readiness SERVING again.
+	db.down.Store(false)
+	r.Eventually(func() bool {
+		return servingStatus(t, healthServer, ReadinessService) == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond)
+	r.Equal(healthpb.HealthCheckResponse_SERVING, servingStatus(t, healthServer, books.Books_ServiceDesc.ServiceName))
+
+	cancel()
+	<-done
+}
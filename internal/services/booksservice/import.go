@@ -0,0 +1,207 @@
+package booksservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/ratelimit"
+	"github.com/celestebrant/library-of-books/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultImportChunkSize is used if BooksServer.importChunkSize is unset.
+	defaultImportChunkSize = 500
+
+	// importSummaryRejections is the most rejections sent in one summary response, which
+	// keeps each response well below gRPC's default message size limit.
+	importSummaryRejections = 1000
+)
+
+// importStore is the storage written by ImportBooks.
+type importStore interface {
+	GetImport(ctx context.Context, importID string) (storage.Import, error)
+	CommitImportChunk(ctx context.Context, c storage.ImportChunk) (storage.Import, error)
+	ListImportRejections(ctx context.Context, importID string, after int64, limit int) ([]storage.ImportRejection, error)
+}
+
+// ImportBooks imports the records streamed by the client, committing them in chunks.
+// The import's progress is sent when the stream starts, which tells a resumed import
+// where to continue from, and after each chunk is committed. Once the client closes its
+// side of the stream, the remaining records are committed and a summary of the whole
+// import is sent, listing every rejected record. The summary is split across as many
+// responses as the rejections need, each with Done set, and the stream ends after the
+// last of them.
+//
+// The valid records of each chunk are charged to the caller's daily write quota before
+// the chunk is committed, and refunded if the commit fails.
+//
+// Records are validated like CreateBook requests, and invalid ones are rejected rather
+// than failing the import. Records for a book ID that already exists are skipped.
+//
+// Returns an error if the first message has no valid import ID, a record's offset skips
+// ahead, another stream commits records for the import concurrently, the write quota is
+// exhausted, or a storage error occurs. Records in chunks committed before the error are
+// kept.
+func (s *BooksServer) ImportBooks(stream books.Books_ImportBooksServer) error {
+	chunkSize := s.importChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultImportChunkSize
+	}
+	return importBooks(stream, s.MysqlStorage, chunkSize)
+}
+
+// importBooks imports the records received from stream into store, committing every
+// chunkSize records.
+func importBooks(stream books.Books_ImportBooksServer, store importStore, chunkSize int) error {
+	ctx := stream.Context()
+	req, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "stream ended before the first message")
+	}
+	if err != nil {
+		return err
+	}
+	if err := ValidateImportBooksRequest(req); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	importID := req.ImportId
+
+	imp, err := store.GetImport(ctx, importID)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to read import", slog.String("import_id", importID), slog.Any("error", err))
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err := stream.Send(importProgress(imp, nil, false)); err != nil {
+		return err
+	}
+
+	chunk := storage.ImportChunk{ImportID: importID, Start: imp.Committed, End: imp.Committed}
+	// The first message may only start the import, without a record.
+	for first := true; ; first = false {
+		if !first || req.Book != nil {
+			if err := addImportRecord(&chunk, req); err != nil {
+				return err
+			}
+		}
+		if chunk.End-chunk.Start == int64(chunkSize) {
+			if imp, err = commitImportChunk(ctx, store, chunk); err != nil {
+				return err
+			}
+			if err := stream.Send(importProgress(imp, chunk.Rejections, false)); err != nil {
+				return err
+			}
+			chunk = storage.ImportChunk{ImportID: importID, Start: imp.Committed, End: imp.Committed}
+		}
+
+		req, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if chunk.End > chunk.Start {
+		if imp, err = commitImportChunk(ctx, store, chunk); err != nil {
+			return err
+		}
+	}
+	return sendImportSummary(stream, store, imp)
+}
+
+// sendImportSummary sends the summary of imp to stream, with importSummaryRejections
+// rejections per response.
+func sendImportSummary(stream books.Books_ImportBooksServer, store importStore, imp storage.Import) error {
+	ctx := stream.Context()
+	after := int64(-1)
+	for {
+		rejections, err := store.ListImportRejections(ctx, imp.ID, after, importSummaryRejections)
+		if err != nil {
+			logging.FromContext(ctx).Error("failed to list import rejections", slog.String("import_id", imp.ID), slog.Any("error", err))
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err := stream.Send(importProgress(imp, rejections, true)); err != nil {
+			return err
+		}
+		if len(rejections) < importSummaryRejections {
+			return nil
+		}
+		after = rejections[len(rejections)-1].Offset
+	}
+}
+
+// addImportRecord adds the record of req to chunk, as a book if it is valid and as a
+// rejection otherwise. Records before the end of chunk have already been received, by
+// this stream or one that committed them, and are ignored.
+func addImportRecord(chunk *storage.ImportChunk, req *books.ImportBooksRequest) error {
+	if req.ImportId != "" && req.ImportId != chunk.ImportID {
+		return status.Errorf(codes.InvalidArgument, "import_id must be %s, as in the first message", chunk.ImportID)
+	}
+	if req.Offset < chunk.End {
+		return nil
+	}
+	if req.Offset > chunk.End {
+		return status.Errorf(codes.InvalidArgument, "record at offset %d was sent before the record at offset %d", req.Offset, chunk.End)
+	}
+	chunk.End++
+
+	// The import ID stands in for the request ID, so that records are validated like
+	// CreateBook requests.
+	create := &books.CreateBookRequest{Book: req.Book, RequestId: chunk.ImportID}
+	if err := ValidateCreateBookRequest(create); err != nil {
+		chunk.Rejections = append(chunk.Rejections, storage.ImportRejection{Offset: req.Offset, Reason: err.Error()})
+		return nil
+	}
+	chunk.Books = append(chunk.Books, storage.NewBookFromRequest(create))
+	return nil
+}
+
+// commitImportChunk charges the books of chunk to the write quota and commits chunk to
+// store, refunding the charge if the commit fails. It returns the updated progress of the
+// import.
+func commitImportChunk(ctx context.Context, store importStore, chunk storage.ImportChunk) (storage.Import, error) {
+	if err := ratelimit.ChargeWrites(ctx, len(chunk.Books)); err != nil {
+		return storage.Import{}, err
+	}
+	imp, err := store.CommitImportChunk(ctx, chunk)
+	if err != nil {
+		ratelimit.RefundWrites(ctx, len(chunk.Books))
+	}
+	if errors.Is(err, storage.ErrImportConflict) {
+		return storage.Import{}, status.Errorf(codes.Aborted,
+			"import %s was continued by another stream, resume from its committed count", chunk.ImportID)
+	}
+	if errors.Is(err, storage.ErrBookExists) {
+		return storage.Import{}, status.Errorf(codes.Aborted,
+			"books of import %s were created concurrently, resume from its committed count", chunk.ImportID)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to commit import chunk",
+			slog.String("import_id", chunk.ImportID), slog.Int64("offset", chunk.Start), slog.Any("error", err))
+		return storage.Import{}, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return imp, nil
+}
+
+// importProgress returns the ImportBooks response reporting imp, with rejections.
+func importProgress(imp storage.Import, rejections []storage.ImportRejection, done bool) *books.ImportBooksResponse {
+	res := &books.ImportBooksResponse{
+		ImportId:          imp.ID,
+		Committed:         imp.Committed,
+		Inserted:          imp.Inserted,
+		SkippedDuplicates: imp.SkippedDuplicates,
+		Rejected:          imp.Rejected,
+		Done:              done,
+	}
+	for _, rej := range rejections {
+		res.Rejections = append(res.Rejections, &books.ImportRejection{Offset: rej.Offset, Reason: rej.Reason})
+	}
+	return res
+}
@@ -0,0 +1,254 @@
+package booksservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/ratelimit"
+	"github.com/celestebrant/library-of-books/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeImportStore is an in-memory import store, which skips duplicate book IDs like
+// storage.MysqlStorage.
+type fakeImportStore struct {
+	imports    map[string]storage.Import
+	rejections map[string][]storage.ImportRejection
+	bookIDs    map[string]bool
+	commitErr  error
+}
+
+func newFakeImportStore(bookIDs ...string) *fakeImportStore {
+	s := &fakeImportStore{
+		imports:    make(map[string]storage.Import),
+		rejections: make(map[string][]storage.ImportRejection),
+		bookIDs:    make(map[string]bool),
+	}
+	for _, id := range bookIDs {
+		s.bookIDs[id] = true
+	}
+	return s
+}
+
+func (s *fakeImportStore) GetImport(_ context.Context, importID string) (storage.Import, error) {
+	imp := s.imports[importID]
+	imp.ID = importID
+	return imp, nil
+}
+
+func (s *fakeImportStore) CommitImportChunk(_ context.Context, c storage.ImportChunk) (storage.Import, error) {
+	if s.commitErr != nil {
+		return storage.Import{}, s.commitErr
+	}
+	imp := s.imports[c.ImportID]
+	imp.ID = c.ImportID
+	if imp.Committed != c.Start {
+		return storage.Import{}, storage.ErrImportConflict
+	}
+	for _, b := range c.Books {
+		if s.bookIDs[b.Id] {
+			imp.SkippedDuplicates++
+			continue
+		}
+		s.bookIDs[b.Id] = true
+		imp.Inserted++
+	}
+	s.rejections[c.ImportID] = append(s.rejections[c.ImportID], c.Rejections...)
+	imp.Rejected += int64(len(c.Rejections))
+	imp.Committed = c.End
+	s.imports[c.ImportID] = imp
+	return imp, nil
+}
+
+func (s *fakeImportStore) ListImportRejections(
+	_ context.Context, importID string, after int64, limit int,
+) ([]storage.ImportRejection, error) {
+	var rejections []storage.ImportRejection
+	for _, rej := range s.rejections[importID] {
+		if rej.Offset > after && len(rejections) < limit {
+			rejections = append(rejections, rej)
+		}
+	}
+	return rejections, nil
+}
+
+// fakeImportStream receives the requests it is created with, and records the responses
+// sent.
+type fakeImportStream struct {
+	grpc.ServerStream
+	reqs []*books.ImportBooksRequest
+	sent []*books.ImportBooksResponse
+}
+
+func (s *fakeImportStream) Context() context.Context { return context.Background() }
+
+func (s *fakeImportStream) Recv() (*books.ImportBooksRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *fakeImportStream) Send(res *books.ImportBooksResponse) error {
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+// importRecords returns requests for import "import-1" with a record for each book ID,
+// from offset start.
+func importRecords(start int64, ids ...string) []*books.ImportBooksRequest {
+	reqs := make([]*books.ImportBooksRequest, len(ids))
+	for i, id := range ids {
+		reqs[i] = &books.ImportBooksRequest{
+			Offset: start + int64(i),
+			Book:   &books.Book{Id: id, Title: "Dune", Author: "Frank Herbert"},
+		}
+	}
+	reqs[0].ImportId = "import-1"
+	return reqs
+}
+
+func TestImportBooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commits in chunks and reports progress", func(t *testing.T) {
+		r := require.New(t)
+		store := newFakeImportStore("existing")
+		reqs := importRecords(0, "b1", "b2", "existing", "b3", "b4")
+		reqs[3].Book.Title = ""
+		stream := &fakeImportStream{reqs: reqs}
+
+		r.NoError(importBooks(stream, store, 2))
+		r.Len(stream.sent, 4)
+		for i, committed := range []int64{0, 2, 4} {
+			r.Equal("import-1", stream.sent[i].ImportId)
+			r.Equal(committed, stream.sent[i].Committed)
+			r.False(stream.sent[i].Done)
+		}
+		r.Len(stream.sent[2].Rejections, 1)
+		r.EqualValues(3, stream.sent[2].Rejections[0].Offset)
+
+		summary := stream.sent[3]
+		r.True(summary.Done)
+		r.EqualValues(5, summary.Committed)
+		r.EqualValues(3, summary.Inserted)
+		r.EqualValues(1, summary.SkippedDuplicates)
+		r.EqualValues(1, summary.Rejected)
+		r.Len(summary.Rejections, 1)
+		expectedErr := ValidationError{"title", "must not be empty"}
+		r.Equal(expectedErr.Error(), summary.Rejections[0].Reason)
+	})
+
+	t.Run("summary pages rejections across responses", func(t *testing.T) {
+		r := require.New(t)
+		reqs := importRecords(0, make([]string, importSummaryRejections+1)...)
+		for _, req := range reqs {
+			req.Book.Title = ""
+		}
+		stream := &fakeImportStream{reqs: reqs}
+
+		r.NoError(importBooks(stream, newFakeImportStore(), len(reqs)))
+		r.Len(stream.sent, 4)
+		for _, summary := range stream.sent[2:] {
+			r.True(summary.Done)
+			r.EqualValues(len(reqs), summary.Rejected)
+		}
+		r.Len(stream.sent[2].Rejections, importSummaryRejections)
+		r.Len(stream.sent[3].Rejections, 1)
+		r.EqualValues(importSummaryRejections, stream.sent[3].Rejections[0].Offset)
+	})
+
+	t.Run("resumes after the last committed chunk", func(t *testing.T) {
+		r := require.New(t)
+		store := newFakeImportStore()
+		r.NoError(importBooks(&fakeImportStream{reqs: importRecords(0, "b1", "b2")}, store, 2))
+
+		// Resending from the start skips the committed records.
+		stream := &fakeImportStream{reqs: importRecords(0, "b1", "b2", "b3")}
+		r.NoError(importBooks(stream, store, 2))
+		r.EqualValues(2, stream.sent[0].Committed)
+		summary := stream.sent[len(stream.sent)-1]
+		r.EqualValues(3, summary.Committed)
+		r.EqualValues(3, summary.Inserted)
+		r.Zero(summary.SkippedDuplicates)
+	})
+
+	t.Run("first message may only start the import", func(t *testing.T) {
+		r := require.New(t)
+		reqs := append([]*books.ImportBooksRequest{{ImportId: "import-1"}}, importRecords(0, "b1")...)
+		stream := &fakeImportStream{reqs: reqs}
+		r.NoError(importBooks(stream, newFakeImportStore(), 2))
+		r.EqualValues(1, stream.sent[len(stream.sent)-1].Inserted)
+	})
+
+	t.Run("missing import ID returns error", func(t *testing.T) {
+		r := require.New(t)
+		reqs := importRecords(0, "b1")
+		reqs[0].ImportId = ""
+		err := importBooks(&fakeImportStream{reqs: reqs}, newFakeImportStore(), 2)
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("offset skipping ahead returns error", func(t *testing.T) {
+		r := require.New(t)
+		reqs := importRecords(0, "b1", "b2")
+		reqs[1].Offset = 2
+		err := importBooks(&fakeImportStream{reqs: reqs}, newFakeImportStore(), 2)
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("concurrent stream returns aborted", func(t *testing.T) {
+		r := require.New(t)
+		store := newFakeImportStore()
+		store.commitErr = storage.ErrImportConflict
+		err := importBooks(&fakeImportStream{reqs: importRecords(0, "b1")}, store, 2)
+		r.Equal(codes.Aborted, status.Code(err))
+	})
+}
+
+func TestCommitImportChunkQuota(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	// The context of an ImportBooks stream with a daily write quota of two books.
+	limiter := ratelimit.New(config.RateLimitConfig{
+		Enabled:         true,
+		DailyWriteQuota: 2,
+		WriteMethods:    []string{"/Books/ImportBooks"},
+	})
+	var ctx context.Context
+	r.NoError(limiter.StreamServerInterceptor()(nil, &fakeImportStream{},
+		&grpc.StreamServerInfo{FullMethod: "/Books/ImportBooks"},
+		func(_ any, stream grpc.ServerStream) error {
+			ctx = stream.Context()
+			return nil
+		}))
+
+	chunk := storage.ImportChunk{ImportID: "import-1", End: 2, Books: []*storage.Book{{Id: "b1"}, {Id: "b2"}}}
+	store := newFakeImportStore()
+	for _, commitErr := range []error{storage.ErrImportConflict, storage.ErrBookExists, errors.New("connection lost")} {
+		store.commitErr = commitErr
+		_, err := commitImportChunk(ctx, store, chunk)
+		r.Error(err)
+		r.NotEqual(codes.ResourceExhausted, status.Code(err))
+	}
+
+	// The failed commits were refunded, so the quota still covers the chunk.
+	store.commitErr = nil
+	imp, err := commitImportChunk(ctx, store, chunk)
+	r.NoError(err)
+	r.EqualValues(2, imp.Inserted)
+
+	chunk = storage.ImportChunk{ImportID: "import-1", Start: 2, End: 3, Books: []*storage.Book{{Id: "b3"}}}
+	_, err = commitImportChunk(ctx, store, chunk)
+	r.Equal(codes.ResourceExhausted, status.Code(err))
+}
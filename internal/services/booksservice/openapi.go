@@ -0,0 +1,16 @@
+package booksservice
+
+import (
+	"net/http"
+
+	books "github.com/celestebrant/library-of-books/books"
+)
+
+// OpenAPIPath is where the gateway serves the OpenAPI v3 document for its REST API.
+const OpenAPIPath = "/openapi.yaml"
+
+// serveOpenAPI writes books.OpenAPIYAML.
+func serveOpenAPI(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(books.OpenAPIYAML)
+}
@@ -0,0 +1,106 @@
+package booksservice
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	t.Parallel()
+
+	url := newTestGateway(t, &fakeBooksServer{})
+	res, err := http.Get(url + OpenAPIPath)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "application/yaml", res.Header.Get("Content-Type"))
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name   string         `yaml:"name"`
+				Schema map[string]any `yaml:"schema"`
+			} `yaml:"parameters"`
+		} `yaml:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `yaml:"properties"`
+			} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	require.NoError(t, yaml.Unmarshal(b, &doc))
+
+	// parameter returns the schema of the named parameter of an operation.
+	parameter := func(path, method, name string) map[string]any {
+		for _, p := range doc.Paths[path][method].Parameters {
+			if p.Name == name {
+				return p.Schema
+			}
+		}
+		t.Fatalf("no parameter %s for %s %s", name, method, path)
+		return nil
+	}
+
+	t.Run("serves the checked-in document", func(t *testing.T) {
+		require.Equal(t, string(books.OpenAPIYAML), string(b))
+	})
+
+	t.Run("version 3", func(t *testing.T) {
+		require.Regexp(t, `^3\.`, doc.OpenAPI)
+	})
+
+	t.Run("covers every route", func(t *testing.T) {
+		r := require.New(t)
+		r.Contains(doc.Paths["/v1/books"], "get")
+		r.Contains(doc.Paths["/v1/books"], "post")
+		r.Contains(doc.Paths["/v1/books/{id}"], "get")
+		r.Contains(doc.Paths["/v1/books:batchCreate"], "post")
+		r.Contains(doc.Paths["/v1/books:batchGet"], "get")
+		r.Contains(doc.Paths["/v1/books:watch"], "get")
+	})
+
+	t.Run("page size limits", func(t *testing.T) {
+		r := require.New(t)
+		schema := parameter("/v1/books", "get", "pageSize")
+		r.Equal("integer", schema["type"])
+		r.EqualValues(1, schema["minimum"])
+		r.EqualValues(pageSizeMaxLength, schema["maximum"])
+	})
+
+	t.Run("book ID path parameter limits", func(t *testing.T) {
+		r := require.New(t)
+		schema := parameter("/v1/books/{id}", "get", "id")
+		r.EqualValues(1, schema["minLength"])
+		r.EqualValues(idMaxLength, schema["maxLength"])
+	})
+
+	t.Run("batch get ID count limits", func(t *testing.T) {
+		r := require.New(t)
+		schema := parameter("/v1/books:batchGet", "get", "ids")
+		r.Equal("array", schema["type"])
+		r.EqualValues(1, schema["minItems"])
+		r.EqualValues(batchGetBooksMaxIDs, schema["maxItems"])
+	})
+
+	t.Run("create request length limits", func(t *testing.T) {
+		r := require.New(t)
+		book := doc.Components.Schemas["Book"].Properties
+		r.EqualValues(idMaxLength, book["id"]["maxLength"])
+		r.EqualValues(1, book["title"]["minLength"])
+		r.EqualValues(titleMaxLength, book["title"]["maxLength"])
+		r.EqualValues(1, book["author"]["minLength"])
+		r.EqualValues(authorMaxLength, book["author"]["maxLength"])
+
+		request := doc.Components.Schemas["CreateBookRequest"].Properties
+		r.EqualValues(1, request["requestId"]["minLength"])
+		r.EqualValues(requestIDMaxLength, request["requestId"]["maxLength"])
+	})
+}
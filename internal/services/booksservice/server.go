@@ -0,0 +1,422 @@
+package booksservice
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/auth"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/internal/metrics"
+	"github.com/celestebrant/library-of-books/internal/outbox"
+	"github.com/celestebrant/library-of-books/internal/ratelimit"
+	"github.com/celestebrant/library-of-books/internal/tlsconfig"
+	"github.com/celestebrant/library-of-books/storage"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Server owns the books gRPC server together with the health service, the REST gateway,
+// the admin HTTP server and the storage they depend on, so that they can be shut down
+// together.
+type Server struct {
+	GRPCServer *grpc.Server
+	Books      *BooksServer
+	Health     *health.Server
+	Storage    *storage.MysqlStorage
+	Metrics    *metrics.Metrics
+
+	// Admin serves operational endpoints such as /metrics and the descriptor set of the
+	// gRPC services. It is nil if no admin address is configured.
+	Admin *http.Server
+
+	// Gateway serves the Books RPCs as REST/JSON by calling them on an in-process gRPC
+	// server with the same interceptors as GRPCServer. It is nil if no gateway address
+	// is configured.
+	Gateway     *http.Server
+	gatewayGRPC *grpc.Server
+	gatewayLis  *bufconn.Listener
+	gatewayConn *grpc.ClientConn
+
+	// outboxSink receives the events published by the outbox relay, which runs until
+	// stopRelay is called if the outbox is enabled.
+	outboxSink *outbox.FileSink
+	stopRelay  context.CancelFunc
+	relayDone  chan struct{}
+
+	// stopPruning stops pruning the change log, which runs if the watch retention is
+	// set.
+	stopPruning context.CancelFunc
+	pruningDone chan struct{}
+
+	shutdownTimeout time.Duration
+	shutdownOnce    sync.Once
+	shutdownErr     error
+	stopMonitor     context.CancelFunc
+	monitorDone     chan struct{}
+}
+
+// NewServer opens the MySQL storage and creates a gRPC server with the books and health
+// services registered, and starts monitoring the database for the readiness checks and,
+// if enabled, pruning the change log and relaying outbox events. It does not start listening; call Serve,
+// ServeGateway and ServeAdmin for that.
+func NewServer(conf config.Config) (*Server, error) {
+	// Create a new database connection that the books server can use to write to the db
+	dbConn, err := storage.NewMysqlStorage(conf.MySQL)
+	if err != nil {
+		return nil, err
+	}
+
+	m := metrics.New()
+	dbConn.SetQueryObserver(m)
+	m.RegisterStorage(&dbConn)
+
+	var outboxSink *outbox.FileSink
+	if conf.Server.Outbox.Enabled {
+		if outboxSink, err = outbox.NewFileSink(conf.Server.Outbox.FilePath); err != nil {
+			dbConn.Close()
+			return nil, err
+		}
+		dbConn.EnableOutbox()
+	}
+	closeAll := func() {
+		if outboxSink != nil {
+			outboxSink.Close()
+		}
+		dbConn.Close()
+	}
+
+	opts, err := ServerOptions(conf.Server)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	unary := []grpc.UnaryServerInterceptor{
+		logging.UnaryServerInterceptor(slog.Default()),
+		m.UnaryServerInterceptor(),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		logging.StreamServerInterceptor(slog.Default()),
+		m.StreamServerInterceptor(),
+	}
+	if conf.Server.Auth.Enabled {
+		authenticator, err := auth.NewAuthenticator(conf.Server.Auth)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		policy := auth.DefaultPolicy()
+		if conf.Server.Auth.PolicyFile != "" {
+			if policy, err = auth.LoadPolicy(conf.Server.Auth.PolicyFile); err != nil {
+				closeAll()
+				return nil, err
+			}
+		}
+		unary = append(unary, authenticator.UnaryServerInterceptor(), policy.UnaryServerInterceptor())
+		stream = append(stream, authenticator.StreamServerInterceptor(), policy.StreamServerInterceptor())
+	}
+	if conf.Server.RateLimit.Enabled {
+		limiter := ratelimit.New(conf.Server.RateLimit)
+		unary = append(unary, limiter.UnaryServerInterceptor())
+		stream = append(stream, limiter.StreamServerInterceptor())
+	}
+
+	booksServer := &BooksServer{
+		MysqlStorage:      &dbConn,
+		watchPollInterval: conf.Server.WatchPollInterval,
+		watchDone:         make(chan struct{}),
+		importChunkSize:   conf.Server.ImportChunkSize,
+	}
+	healthServer := newHealthServer()
+	newGRPCServer := func(opts []grpc.ServerOption, unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor) *grpc.Server {
+		s := grpc.NewServer(append(opts,
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.ChainUnaryInterceptor(unary...),
+			grpc.ChainStreamInterceptor(stream...),
+		)...)
+		books.RegisterBooksServer(s, booksServer)
+		healthpb.RegisterHealthServer(s, healthServer)
+		return s
+	}
+
+	// Create a new gRPC server registered with booksServer
+	grpcServer := newGRPCServer(opts, unary, stream)
+	if conf.Server.Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &Server{
+		GRPCServer:      grpcServer,
+		Books:           booksServer,
+		Health:          healthServer,
+		Storage:         &dbConn,
+		Metrics:         m,
+		shutdownTimeout: conf.Server.ShutdownTimeout,
+		stopMonitor:     cancel,
+		monitorDone:     make(chan struct{}),
+	}
+	if conf.Server.AdminAddress != "" {
+		server.Admin = newAdminServer(conf.Server, m)
+	}
+	if conf.Server.GatewayAddress != "" {
+		// The gateway's gRPC server trusts the client address the gateway forwards.
+		server.gatewayGRPC = newGRPCServer(
+			[]grpc.ServerOption{grpc.ConnectionTimeout(conf.Server.ConnectionTimeout)},
+			append([]grpc.UnaryServerInterceptor{gatewayPeerUnaryInterceptor}, unary...),
+			append([]grpc.StreamServerInterceptor{gatewayPeerStreamInterceptor}, stream...),
+		)
+		if err := server.setUpGateway(conf.Server); err != nil {
+			server.gatewayGRPC.Stop()
+			cancel()
+			closeAll()
+			return nil, err
+		}
+	}
+	go func() {
+		defer close(server.monitorDone)
+		monitorDatabase(ctx, healthServer, &dbConn, conf.Server.HealthCheckInterval, conf.Server.HealthCheckTimeout)
+	}()
+	if conf.Server.WatchRetention > 0 {
+		pruneCtx, stopPruning := context.WithCancel(context.Background())
+		server.stopPruning = stopPruning
+		server.pruningDone = make(chan struct{})
+		go func() {
+			defer close(server.pruningDone)
+			pruneChangeLog(pruneCtx, &dbConn, conf.Server.WatchRetention, changeLogPruneInterval)
+		}()
+	}
+	if outboxSink != nil {
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		server.outboxSink = outboxSink
+		server.stopRelay = stopRelay
+		server.relayDone = make(chan struct{})
+		relay := outbox.NewRelay(conf.Server.Outbox, &dbConn, outboxSink)
+		go func() {
+			defer close(server.relayDone)
+			relay.Run(relayCtx)
+		}()
+	}
+
+	return server, nil
+}
+
+// setUpGateway creates the REST gateway and its connection to gatewayGRPC. The
+// connection and its listener are closed if it fails.
+func (s *Server) setUpGateway(conf config.ServerConfig) error {
+	lis := bufconn.Listen(gatewayBufferSize)
+	conn, err := dialGateway(lis)
+	if err != nil {
+		lis.Close()
+		return fmt.Errorf("cannot connect REST gateway: %w", err)
+	}
+	handler, err := newGatewayHandler(context.Background(), conn)
+	if err != nil {
+		conn.Close()
+		lis.Close()
+		return fmt.Errorf("cannot register REST gateway: %w", err)
+	}
+
+	var tlsConf *tls.Config
+	if conf.TLS.Enabled {
+		if tlsConf, err = tlsconfig.Server(conf.TLS); err != nil {
+			conn.Close()
+			lis.Close()
+			return fmt.Errorf("cannot load gateway TLS credentials: %w", err)
+		}
+	}
+
+	s.gatewayLis = lis
+	s.gatewayConn = conn
+	s.Gateway = newGatewayServer(conf.GatewayAddress, handler, tlsConf)
+	return nil
+}
+
+// newAdminServer returns an HTTP server for operational endpoints: the Prometheus
+// metrics and the descriptor set of the gRPC services.
+func newAdminServer(conf config.ServerConfig, m *metrics.Metrics) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc(DescriptorSetPath, serveDescriptorSet)
+
+	return &http.Server{
+		Addr:              conf.AdminAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// Serve accepts gRPC connections on lis until the server is shut down. It returns nil
+// after a Shutdown.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.GRPCServer.Serve(lis)
+}
+
+// ServeGateway accepts REST gateway HTTP connections on lis until the server is shut
+// down. It returns nil after a Shutdown.
+func (s *Server) ServeGateway(lis net.Listener) error {
+	go s.gatewayGRPC.Serve(s.gatewayLis)
+
+	var err error
+	if s.Gateway.TLSConfig != nil {
+		err = s.Gateway.ServeTLS(lis, "", "")
+	} else {
+		err = s.Gateway.Serve(lis)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ServeAdmin accepts admin HTTP connections on lis until the server is shut down. It
+// returns nil after a Shutdown.
+func (s *Server) ServeAdmin(lis net.Listener) error {
+	if err := s.Admin.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown marks every service NOT_SERVING and ends WatchBooks streams, then waits up
+// to the configured shutdown timeout for in-flight RPCs, gateway and admin requests to
+// finish before stopping the servers forcefully. Change log pruning and the outbox relay
+// are stopped, and the storage closed, afterwards in either case.
+//
+// Returns ErrShutdownTimeout if the server had to be stopped forcefully, and an error
+// if the storage cannot be closed. It may be called more than once, and later calls
+// return the result of the first.
+func (s *Server) Shutdown() error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown()
+	})
+	return s.shutdownErr
+}
+
+func (s *Server) shutdown() error {
+	s.stopMonitor()
+	<-s.monitorDone
+	s.Health.Shutdown()
+	s.Books.stopWatches()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GRPCServer.GracefulStop()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.GRPCServer.Stop()
+		<-stopped
+		err = ErrShutdownTimeout
+	}
+
+	if s.Gateway != nil {
+		if gatewayErr := s.Gateway.Shutdown(ctx); gatewayErr != nil {
+			s.Gateway.Close()
+			err = ErrShutdownTimeout
+		}
+		s.gatewayConn.Close()
+		s.gatewayGRPC.Stop()
+	}
+
+	if s.Admin != nil {
+		if adminErr := s.Admin.Shutdown(ctx); adminErr != nil {
+			s.Admin.Close()
+			err = ErrShutdownTimeout
+		}
+	}
+
+	if s.stopPruning != nil {
+		s.stopPruning()
+		<-s.pruningDone
+	}
+
+	if s.stopRelay != nil {
+		s.stopRelay()
+		<-s.relayDone
+		if closeErr := s.outboxSink.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("cannot close outbox file: %w", closeErr))
+		}
+	}
+
+	if closeErr := s.Storage.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("cannot close MySQL storage: %w", closeErr))
+	}
+
+	return err
+}
+
+// MustNewBooksServer creates a new books Server serving gRPC in a goroutine, and panics
+// if setup fails. The admin server is not started. Returns the server and its network
+// listener, and subsequent closure should be deferred with StopBooksServer.
+func MustNewBooksServer(conf config.Config, wg *sync.WaitGroup) (*Server, net.Listener) {
+	// Create a network listener
+	lis, err := net.Listen("tcp", conf.Server.Address)
+	if err != nil {
+		panic(fmt.Errorf("failed to listen: %w", err))
+	}
+
+	server, err := NewServer(conf)
+	if err != nil {
+		panic(err)
+	}
+	slog.Info("gRPC server books listening", slog.String("address", conf.Server.Address))
+
+	// Connect the new server to the network listener in a goroutine
+	go func() {
+		defer wg.Done()
+		if err := server.Serve(lis); err != nil {
+			panic(fmt.Errorf("failed to serve: %w", err))
+		}
+	}()
+
+	return server, lis
+}
+
+// ServerOptions returns the transport options for conf, such as the connection timeout
+// and TLS credentials if enabled.
+func ServerOptions(conf config.ServerConfig) ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{
+		grpc.ConnectionTimeout(conf.ConnectionTimeout),
+	}
+
+	if conf.TLS.Enabled {
+		tlsConf, err := tlsconfig.Server(conf.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load server TLS credentials: %w", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConf)))
+	}
+
+	return opts, nil
+}
+
+// StopBooksServer gracefully shuts down server, closes lis and waits until wg is zero.
+func StopBooksServer(server *Server, lis net.Listener, wg *sync.WaitGroup) {
+	if err := server.Shutdown(); err != nil {
+		slog.Error("books server did not shut down cleanly", slog.Any("error", err))
+	}
+	lis.Close()
+	wg.Wait()
+}
@@ -0,0 +1,129 @@
+package booksservice
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/outbox"
+	"github.com/celestebrant/library-of-books/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// blockingBooksServer holds GetBook calls open until release is closed or the call is
+// cancelled, and signals started when one begins.
+type blockingBooksServer struct {
+	books.UnimplementedBooksServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingBooksServer) GetBook(ctx context.Context, req *books.GetBookRequest) (*books.GetBookResponse, error) {
+	close(s.started)
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+	}
+	return &books.GetBookResponse{Book: &books.Book{Id: req.Id}}, nil
+}
+
+// newTestServer returns a Server with a GetBook call to fake in flight, stopping
+// forcefully after shutdownTimeout, and a channel receiving the call's error. Its
+// storage is not connected to a database.
+func newTestServer(t *testing.T, fake *blockingBooksServer, shutdownTimeout time.Duration) (*Server, chan error) {
+	t.Helper()
+	// Opening the database does not connect to it.
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/library")
+	require.NoError(t, err)
+	dbConn := storage.NewMysqlStorageFromDB(db)
+
+	grpcServer := grpc.NewServer()
+	books.RegisterBooksServer(grpcServer, fake)
+	lis := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(lis)
+
+	monitorDone := make(chan struct{})
+	close(monitorDone)
+	server := &Server{
+		GRPCServer:      grpcServer,
+		Books:           &BooksServer{watchDone: make(chan struct{})},
+		Health:          newHealthServer(),
+		Storage:         &dbConn,
+		shutdownTimeout: shutdownTimeout,
+		stopMonitor:     func() {},
+		monitorDone:     monitorDone,
+	}
+
+	conn, err := grpc.Dial("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := books.NewBooksClient(conn).GetBook(context.Background(), &books.GetBookRequest{Id: "b1"})
+		errs <- err
+	}()
+	<-fake.started
+	return server, errs
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("in-flight RPC finishing within the timeout is drained", func(t *testing.T) {
+		r := require.New(t)
+		fake := &blockingBooksServer{started: make(chan struct{}), release: make(chan struct{})}
+		server, errs := newTestServer(t, fake, 5*time.Second)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(fake.release)
+		}()
+		r.NoError(server.Shutdown())
+		r.NoError(<-errs)
+		r.ErrorContains(server.Storage.Ping(context.Background()), "database is closed")
+	})
+
+	t.Run("in-flight RPC past the timeout is stopped forcefully", func(t *testing.T) {
+		r := require.New(t)
+		fake := &blockingBooksServer{started: make(chan struct{}), release: make(chan struct{})}
+		server, errs := newTestServer(t, fake, 50*time.Millisecond)
+
+		start := time.Now()
+		r.ErrorIs(server.Shutdown(), ErrShutdownTimeout)
+		r.Less(time.Since(start), 5*time.Second)
+		r.Error(<-errs)
+		r.ErrorContains(server.Storage.Ping(context.Background()), "database is closed")
+	})
+
+	t.Run("second shutdown returns the result of the first", func(t *testing.T) {
+		r := require.New(t)
+		fake := &blockingBooksServer{started: make(chan struct{}), release: make(chan struct{})}
+		server, errs := newTestServer(t, fake, 50*time.Millisecond)
+
+		// The outbox file would fail to close a second time.
+		sink, err := outbox.NewFileSink(filepath.Join(t.TempDir(), "events.jsonl"))
+		r.NoError(err)
+		relayDone := make(chan struct{})
+		close(relayDone)
+		server.outboxSink, server.stopRelay, server.relayDone = sink, func() {}, relayDone
+
+		r.ErrorIs(server.Shutdown(), ErrShutdownTimeout)
+		r.Error(<-errs)
+		err = server.Shutdown()
+		r.ErrorIs(err, ErrShutdownTimeout)
+		r.NotContains(err.Error(), "outbox")
+	})
+}
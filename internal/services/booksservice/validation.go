@@ -17,9 +17,13 @@ type Book struct {
 const (
 	idMaxLength        = 30
 	requestIDMaxLength = 30
+	importIDMaxLength  = 30
 	authorMaxLength    = 255
 	titleMaxLength     = 255
 	pageSizeMaxLength  = 50
+
+	batchCreateBooksMaxRequests = 1000
+	batchGetBooksMaxIDs         = 100
 )
 
 /*
@@ -42,7 +46,7 @@ func ValidateCreateBookRequest(req *books.CreateBookRequest) error {
 		}
 	}
 
-	if len(req.Book.Author) == 0 {
+	if len(req.GetBook().GetAuthor()) == 0 {
 		return &ValidationError{
 			Field:   "author",
 			Message: "must not be empty",
@@ -54,7 +58,7 @@ func ValidateCreateBookRequest(req *books.CreateBookRequest) error {
 		}
 	}
 
-	if len(req.Book.Title) == 0 {
+	if len(req.GetBook().GetTitle()) == 0 {
 		return &ValidationError{
 			Field:   "title",
 			Message: "must not be empty",
@@ -86,3 +90,90 @@ func ValidateListBooksRequest(req *books.ListBooksRequest) error {
 	}
 	return nil
 }
+
+// ValidateGetBookRequest returns an error if the book ID is empty or exceeds the maximum
+// allowed length.
+func ValidateGetBookRequest(req *books.GetBookRequest) error {
+	if len(req.Id) == 0 {
+		return &ValidationError{
+			Field:   "id",
+			Message: "must not be empty",
+		}
+	} else if len(req.Id) > idMaxLength {
+		return &ValidationError{
+			Field:   "id",
+			Message: fmt.Sprintf("must not exceed %d characters", idMaxLength),
+		}
+	}
+	return nil
+}
+
+// ValidateBatchCreateBooksRequest returns an error if the number of requests is outside
+// limits (1 - 1000). The requests themselves are validated with
+// ValidateCreateBookRequest.
+func ValidateBatchCreateBooksRequest(req *books.BatchCreateBooksRequest) error {
+	if len(req.Requests) == 0 || len(req.Requests) > batchCreateBooksMaxRequests {
+		return &ValidationError{
+			Field:   "requests",
+			Message: fmt.Sprintf("must contain between 1 and %d requests", batchCreateBooksMaxRequests),
+		}
+	}
+	return nil
+}
+
+// ValidateBatchGetBooksRequest returns an error if the number of IDs is outside limits
+// (1 - 100), or an ID is empty or exceeds the maximum allowed length.
+func ValidateBatchGetBooksRequest(req *books.BatchGetBooksRequest) error {
+	if len(req.Ids) == 0 || len(req.Ids) > batchGetBooksMaxIDs {
+		return &ValidationError{
+			Field:   "ids",
+			Message: fmt.Sprintf("must contain between 1 and %d IDs", batchGetBooksMaxIDs),
+		}
+	}
+	for i, id := range req.Ids {
+		if len(id) == 0 {
+			return &ValidationError{
+				Field:   fmt.Sprintf("ids[%d]", i),
+				Message: "must not be empty",
+			}
+		} else if len(id) > idMaxLength {
+			return &ValidationError{
+				Field:   fmt.Sprintf("ids[%d]", i),
+				Message: fmt.Sprintf("must not exceed %d characters", idMaxLength),
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateWatchBooksRequest returns an error if the cursor is set but was not returned by
+// WatchBooks.
+func ValidateWatchBooksRequest(req *books.WatchBooksRequest) error {
+	if req.Cursor == "" {
+		return nil
+	}
+	if _, err := parseWatchCursor(req.Cursor); err != nil {
+		return &ValidationError{
+			Field:   "cursor",
+			Message: "is not a valid cursor",
+		}
+	}
+	return nil
+}
+
+// ValidateImportBooksRequest returns an error if the import ID of the first message of
+// an ImportBooks stream is empty or exceeds the maximum allowed length.
+func ValidateImportBooksRequest(req *books.ImportBooksRequest) error {
+	if len(req.ImportId) == 0 {
+		return &ValidationError{
+			Field:   "import_id",
+			Message: "must not be empty",
+		}
+	} else if len(req.ImportId) > importIDMaxLength {
+		return &ValidationError{
+			Field:   "import_id",
+			Message: fmt.Sprintf("must not exceed %d characters", importIDMaxLength),
+		}
+	}
+	return nil
+}
@@ -31,6 +31,17 @@ func TestValidateCreateBookRequest(t *testing.T) {
 		r.NoError(err)
 	})
 
+	t.Run("omitted book returns error", func(t *testing.T) {
+		r := require.New(t)
+
+		req := newValidCreateBookRequest()
+		req.Book = nil
+
+		err := ValidateCreateBookRequest(req)
+		expectedErr := ValidationError{"author", "must not be empty"}
+		r.EqualError(err, expectedErr.Error())
+	})
+
 	t.Run("omitted request ID returns error", func(t *testing.T) {
 		r := require.New(t)
 
@@ -140,6 +151,30 @@ func TestValidateCreateBookRequest(t *testing.T) {
 	})
 }
 
+func TestValidateGetBookRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateGetBookRequest(&books.GetBookRequest{Id: utils.StringWithLength(idMaxLength)})
+		r.NoError(err)
+	})
+
+	t.Run("omitted ID returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateGetBookRequest(&books.GetBookRequest{})
+		expectedErr := ValidationError{"id", "must not be empty"}
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("ID too long returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateGetBookRequest(&books.GetBookRequest{Id: utils.StringWithLength(idMaxLength + 1)})
+		expectedErr := ValidationError{"id", "must not exceed 30 characters"}
+		r.EqualError(err, expectedErr.Error())
+	})
+}
+
 func TestValidateListBooksRequest(t *testing.T) {
 	t.Parallel()
 
@@ -191,3 +226,144 @@ func TestValidateListBooksRequest(t *testing.T) {
 		r.EqualError(err, expectedErr.Error())
 	})
 }
+
+func TestValidateBatchCreateBooksRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid upper boundary", func(t *testing.T) {
+		r := require.New(t)
+		req := &books.BatchCreateBooksRequest{
+			Requests: make([]*books.CreateBookRequest, batchCreateBooksMaxRequests),
+		}
+		r.NoError(ValidateBatchCreateBooksRequest(req))
+	})
+
+	expectedErr := ValidationError{
+		"requests",
+		"must contain between 1 and 1000 requests",
+	}
+
+	t.Run("empty batch returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateBatchCreateBooksRequest(&books.BatchCreateBooksRequest{})
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("too many requests returns error", func(t *testing.T) {
+		r := require.New(t)
+		req := &books.BatchCreateBooksRequest{
+			Requests: make([]*books.CreateBookRequest, batchCreateBooksMaxRequests+1),
+		}
+		err := ValidateBatchCreateBooksRequest(req)
+		r.EqualError(err, expectedErr.Error())
+	})
+}
+
+func TestValidateBatchGetBooksRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid upper boundary", func(t *testing.T) {
+		r := require.New(t)
+		req := &books.BatchGetBooksRequest{}
+		for i := 0; i < batchGetBooksMaxIDs; i++ {
+			req.Ids = append(req.Ids, utils.StringWithLength(idMaxLength))
+		}
+		r.NoError(ValidateBatchGetBooksRequest(req))
+	})
+
+	t.Run("no IDs returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateBatchGetBooksRequest(&books.BatchGetBooksRequest{})
+		expectedErr := ValidationError{
+			"ids",
+			"must contain between 1 and 100 IDs",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("too many IDs returns error", func(t *testing.T) {
+		r := require.New(t)
+		req := &books.BatchGetBooksRequest{Ids: make([]string, batchGetBooksMaxIDs+1)}
+		err := ValidateBatchGetBooksRequest(req)
+		expectedErr := ValidationError{
+			"ids",
+			"must contain between 1 and 100 IDs",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("empty ID returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateBatchGetBooksRequest(&books.BatchGetBooksRequest{Ids: []string{"b1", ""}})
+		expectedErr := ValidationError{
+			"ids[1]",
+			"must not be empty",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("ID too long returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateBatchGetBooksRequest(&books.BatchGetBooksRequest{
+			Ids: []string{utils.StringWithLength(idMaxLength + 1)},
+		})
+		expectedErr := ValidationError{
+			"ids[0]",
+			"must not exceed 30 characters",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+}
+
+func TestValidateWatchBooksRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty cursor", func(t *testing.T) {
+		r := require.New(t)
+		r.NoError(ValidateWatchBooksRequest(&books.WatchBooksRequest{}))
+	})
+
+	t.Run("returned cursor", func(t *testing.T) {
+		r := require.New(t)
+		r.NoError(ValidateWatchBooksRequest(&books.WatchBooksRequest{Cursor: watchCursor(7)}))
+	})
+
+	t.Run("malformed cursor returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateWatchBooksRequest(&books.WatchBooksRequest{Cursor: "MTA="})
+		expectedErr := ValidationError{
+			"cursor",
+			"is not a valid cursor",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+}
+
+func TestValidateImportBooksRequest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid import ID", func(t *testing.T) {
+		r := require.New(t)
+		r.NoError(ValidateImportBooksRequest(&books.ImportBooksRequest{ImportId: "import-1"}))
+	})
+
+	t.Run("empty import ID returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateImportBooksRequest(&books.ImportBooksRequest{})
+		expectedErr := ValidationError{
+			"import_id",
+			"must not be empty",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+
+	t.Run("import ID too long returns error", func(t *testing.T) {
+		r := require.New(t)
+		err := ValidateImportBooksRequest(&books.ImportBooksRequest{ImportId: utils.StringWithLength(31)})
+		expectedErr := ValidationError{
+			"import_id",
+			"must not exceed 30 characters",
+		}
+		r.EqualError(err, expectedErr.Error())
+	})
+}
@@ -0,0 +1,219 @@
+package booksservice
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/logging"
+	"github.com/celestebrant/library-of-books/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// WatchCursorHeader is the response header metadata carrying the cursor a
+	// WatchBooks stream starts from, so that a client that sees no changes before
+	// disconnecting can still resume without missing any.
+	WatchCursorHeader = "x-watch-cursor"
+
+	// defaultWatchPollInterval is used if BooksServer.watchPollInterval is unset.
+	defaultWatchPollInterval = time.Second
+
+	// watchBatchSize is the most changes read from the change log per poll.
+	watchBatchSize = 100
+
+	// changeLogPruneInterval is how often changes older than the watch retention are
+	// pruned from the change log.
+	changeLogPruneInterval = time.Hour
+)
+
+var (
+	// errWatchStopped ends WatchBooks streams when the server shuts down.
+	errWatchStopped = status.Error(codes.Unavailable, "server is shutting down, resume from the last cursor received")
+
+	// errCursorPruned ends WatchBooks streams whose cursor is older than the retained
+	// change log, as the changes after it can no longer be sent.
+	errCursorPruned = status.Error(codes.OutOfRange,
+		"cursor is older than the retained change log, read the books again and watch without a cursor")
+)
+
+// changeLog is the storage read by WatchBooks.
+type changeLog interface {
+	BookChangeRange(ctx context.Context) (pruned, latest uint64, err error)
+	ListBookChanges(ctx context.Context, after uint64, limit int) ([]storage.BookChange, error)
+}
+
+// changePruner is the storage pruned by pruneChangeLog.
+type changePruner interface {
+	PruneBookChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// WatchBooks streams changes to books from the change log, starting after the request
+// cursor, or with changes committed after the call if it is empty. The stream ends with
+// an Unavailable error when the server shuts down, and clients should then resume from
+// the cursor of the last change they received.
+//
+// Returns an error if the cursor is invalid, ahead of the change log or older than the
+// changes it retains, or a storage error occurs.
+func (s *BooksServer) WatchBooks(req *books.WatchBooksRequest, stream books.Books_WatchBooksServer) error {
+	if err := ValidateWatchBooksRequest(req); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	interval := s.watchPollInterval
+	if interval <= 0 {
+		interval = defaultWatchPollInterval
+	}
+	return watchBooks(stream, s.MysqlStorage, req.Cursor, interval, s.watchDone)
+}
+
+// stopWatches ends every WatchBooks stream, which would otherwise hold up a graceful
+// stop indefinitely. It may be called more than once.
+func (s *BooksServer) stopWatches() {
+	s.stopWatchesOnce.Do(func() {
+		if s.watchDone != nil {
+			close(s.watchDone)
+		}
+	})
+}
+
+// watchBooks sends the changes in log after cursor to stream, polling for new ones every
+// interval until the stream's context is done or done is closed.
+func watchBooks(
+	stream books.Books_WatchBooksServer, log changeLog, cursor string, interval time.Duration, done <-chan struct{},
+) error {
+	ctx := stream.Context()
+	pruned, latest, err := log.BookChangeRange(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to read change log", slog.Any("error", err))
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	after := latest
+	if cursor != "" {
+		// Validated by ValidateWatchBooksRequest.
+		after, _ = parseWatchCursor(cursor)
+		if after > latest {
+			return status.Errorf(codes.OutOfRange, "cursor is ahead of the change log")
+		}
+		if after < pruned {
+			return errCursorPruned
+		}
+	}
+	if err := stream.SendHeader(metadata.Pairs(WatchCursorHeader, watchCursor(after))); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		changes, err := log.ListBookChanges(ctx, after, watchBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return status.FromContextError(ctx.Err()).Err()
+			}
+			logging.FromContext(ctx).Error("failed to read change log", slog.Any("error", err))
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+		// Sequence numbers are allocated in the transaction that commits the change, so
+		// they have no gaps, and a gap means the changes after the cursor were pruned
+		// before this stream read them.
+		if len(changes) > 0 && changes[0].Sequence != after+1 {
+			return errCursorPruned
+		}
+		for _, c := range changes {
+			if err := stream.Send(changeEvent(c)); err != nil {
+				return err
+			}
+			after = c.Sequence
+		}
+
+		if len(changes) == watchBatchSize {
+			// Catch up on the backlog without waiting for the next poll.
+			select {
+			case <-done:
+				return errWatchStopped
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-done:
+			return errWatchStopped
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneChangeLog deletes the changes older than retention from log every interval until
+// ctx is done.
+func pruneChangeLog(ctx context.Context, log changePruner, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := log.PruneBookChanges(ctx, time.Now().Add(-retention))
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("failed to prune change log", slog.Int64("deleted", n), slog.Any("error", err))
+		} else if n > 0 {
+			slog.Info("pruned change log", slog.Int64("deleted", n))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// changeEvent returns the WatchBooks response for c.
+func changeEvent(c storage.BookChange) *books.WatchBooksResponse {
+	return &books.WatchBooksResponse{
+		Type: changeTypes[c.Type],
+		Book: &books.Book{
+			Id:           c.Book.Id,
+			Title:        c.Book.Title,
+			Author:       c.Book.Author,
+			CreationTime: timestamppb.New(c.Book.CreationTime),
+		},
+		ChangeTime: timestamppb.New(c.ChangeTime),
+		Cursor:     watchCursor(c.Sequence),
+	}
+}
+
+var changeTypes = map[storage.ChangeType]books.WatchBooksResponse_ChangeType{
+	storage.ChangeCreated: books.WatchBooksResponse_CREATED,
+	storage.ChangeUpdated: books.WatchBooksResponse_UPDATED,
+	storage.ChangeDeleted: books.WatchBooksResponse_DELETED,
+}
+
+// watchCursor returns the cursor resuming after the change with sequence number seq. It
+// is URL-safe so that it can be passed as a REST query parameter.
+func watchCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+// parseWatchCursor returns the sequence number of the change that cursor resumes after.
+func parseWatchCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode cursor: %w", err)
+	}
+	seq, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse decoded cursor: %w", err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,278 @@
+package booksservice
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeChangeLog is an in-memory change log.
+type fakeChangeLog struct {
+	mu      sync.Mutex
+	pruned  uint64
+	changes []storage.BookChange
+}
+
+// add appends a creation of a book with id to the log.
+func (l *fakeChangeLog) add(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, storage.BookChange{
+		Sequence:   l.pruned + uint64(len(l.changes)) + 1,
+		Type:       storage.ChangeCreated,
+		ChangeTime: time.Now(),
+		Book:       storage.Book{Id: id, Title: "Dune", Author: "Frank Herbert"},
+	})
+}
+
+// prune deletes the changes up to and including the one with sequence number seq.
+func (l *fakeChangeLog) prune(seq uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = l.changes[seq-l.pruned:]
+	l.pruned = seq
+}
+
+func (l *fakeChangeLog) BookChangeRange(context.Context) (uint64, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pruned, l.pruned + uint64(len(l.changes)), nil
+}
+
+func (l *fakeChangeLog) ListBookChanges(_ context.Context, after uint64, limit int) ([]storage.BookChange, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var changes []storage.BookChange
+	for _, c := range l.changes {
+		if c.Sequence > after && len(changes) < limit {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (l *fakeChangeLog) PruneBookChanges(_ context.Context, before time.Time) (int64, error) {
+	l.mu.Lock()
+	n := 0
+	for n < len(l.changes) && l.changes[n].ChangeTime.Before(before) {
+		n++
+	}
+	l.mu.Unlock()
+	if n > 0 {
+		l.prune(l.pruned + uint64(n))
+	}
+	return int64(n), nil
+}
+
+// fakeWatchStream passes sent responses and the header to channels.
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header chan metadata.MD
+	sent   chan *books.WatchBooksResponse
+}
+
+func newFakeWatchStream(ctx context.Context) *fakeWatchStream {
+	return &fakeWatchStream{
+		ctx:    ctx,
+		header: make(chan metadata.MD, 1),
+		sent:   make(chan *books.WatchBooksResponse, 1000),
+	}
+}
+
+func (s *fakeWatchStream) Context() context.Context { return s.ctx }
+
+func (s *fakeWatchStream) SendHeader(md metadata.MD) error {
+	s.header <- md
+	return nil
+}
+
+func (s *fakeWatchStream) Send(res *books.WatchBooksResponse) error {
+	s.sent <- res
+	return nil
+}
+
+// startWatch runs watchBooks in a goroutine and returns its stream and a channel
+// receiving its error. The watch is cancelled when the test ends.
+func startWatch(t *testing.T, log changeLog, cursor string, done <-chan struct{}) (*fakeWatchStream, <-chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream := newFakeWatchStream(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- watchBooks(stream, log, cursor, time.Millisecond, done)
+	}()
+	return stream, errs
+}
+
+// receive returns the next response sent to stream.
+func receive(t *testing.T, stream *fakeWatchStream) *books.WatchBooksResponse {
+	t.Helper()
+	select {
+	case res := <-stream.sent:
+		return res
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change")
+		return nil
+	}
+}
+
+func TestWatchBooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty cursor starts with new changes", func(t *testing.T) {
+		r := require.New(t)
+		log := &fakeChangeLog{}
+		log.add("old")
+		stream, _ := startWatch(t, log, "", nil)
+
+		r.Equal([]string{watchCursor(1)}, (<-stream.header).Get(WatchCursorHeader))
+		log.add("new")
+		res := receive(t, stream)
+		r.Equal("new", res.Book.Id)
+		r.Equal(books.WatchBooksResponse_CREATED, res.Type)
+		r.Equal(watchCursor(2), res.Cursor)
+	})
+
+	t.Run("cursor resumes after its change", func(t *testing.T) {
+		r := require.New(t)
+		log := &fakeChangeLog{}
+		log.add("b1")
+		log.add("b2")
+		log.add("b3")
+		stream, _ := startWatch(t, log, watchCursor(1), nil)
+
+		r.Equal("b2", receive(t, stream).Book.Id)
+		r.Equal("b3", receive(t, stream).Book.Id)
+	})
+
+	t.Run("backlog larger than a batch is delivered in order", func(t *testing.T) {
+		r := require.New(t)
+		log := &fakeChangeLog{}
+		for i := 0; i < 2*watchBatchSize+1; i++ {
+			log.add("b")
+		}
+		stream, _ := startWatch(t, log, watchCursor(0), nil)
+
+		for i := 1; i <= 2*watchBatchSize+1; i++ {
+			r.Equal(watchCursor(uint64(i)), receive(t, stream).Cursor)
+		}
+	})
+
+	t.Run("cursor ahead of the change log is out of range", func(t *testing.T) {
+		r := require.New(t)
+		_, errs := startWatch(t, &fakeChangeLog{}, watchCursor(5), nil)
+		r.Equal(codes.OutOfRange, status.Code(<-errs))
+	})
+
+	t.Run("cursor older than the retained change log is out of range", func(t *testing.T) {
+		r := require.New(t)
+		log := &fakeChangeLog{}
+		log.add("b1")
+		log.add("b2")
+		log.add("b3")
+		log.prune(2)
+
+		_, errs := startWatch(t, log, watchCursor(1), nil)
+		r.Equal(codes.OutOfRange, status.Code(<-errs))
+
+		stream, _ := startWatch(t, log, watchCursor(2), nil)
+		r.Equal("b3", receive(t, stream).Book.Id)
+	})
+
+	t.Run("changes pruned before the stream reads them end it as out of range", func(t *testing.T) {
+		r := require.New(t)
+		log := &fakeChangeLog{}
+		stream, errs := startWatch(t, log, "", nil)
+		<-stream.header
+
+		// The first change is pruned before the next poll can read it, which the stream
+		// notices when it reads the second.
+		log.mu.Lock()
+		log.changes = []storage.BookChange{{Sequence: 2, Type: storage.ChangeCreated}}
+		log.pruned = 1
+		log.mu.Unlock()
+		r.Equal(codes.OutOfRange, status.Code(<-errs))
+	})
+
+	t.Run("stopping watches ends the stream as unavailable", func(t *testing.T) {
+		r := require.New(t)
+		server := &BooksServer{watchDone: make(chan struct{})}
+		stream, errs := startWatch(t, &fakeChangeLog{}, "", server.watchDone)
+		<-stream.header
+
+		server.stopWatches()
+		r.Equal(codes.Unavailable, status.Code(<-errs))
+	})
+
+	t.Run("stopping watches twice does not panic", func(t *testing.T) {
+		r := require.New(t)
+		server := &BooksServer{watchDone: make(chan struct{})}
+		server.stopWatches()
+		r.NotPanics(server.stopWatches)
+	})
+
+	t.Run("cancelled stream ends", func(t *testing.T) {
+		r := require.New(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := newFakeWatchStream(ctx)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- watchBooks(stream, &fakeChangeLog{}, "", time.Millisecond, nil)
+		}()
+		<-stream.header
+
+		cancel()
+		r.Equal(codes.Canceled, status.Code(<-errs))
+	})
+}
+
+func TestPruneChangeLog(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	log := &fakeChangeLog{}
+	log.add("old")
+	log.changes[0].ChangeTime = time.Now().Add(-2 * time.Hour)
+	log.add("new")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pruneChangeLog(ctx, log, time.Hour, time.Millisecond)
+	}()
+	r.Eventually(func() bool {
+		pruned, _, _ := log.BookChangeRange(ctx)
+		return pruned == 1
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	changes, err := log.ListBookChanges(context.Background(), 0, 10)
+	r.NoError(err)
+	r.Len(changes, 1)
+	r.Equal("new", changes[0].Book.Id)
+}
+
+func TestWatchCursor(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	seq, err := parseWatchCursor(watchCursor(42))
+	r.NoError(err)
+	r.Equal(uint64(42), seq)
+
+	_, err = parseWatchCursor("not a cursor")
+	r.Error(err)
+}
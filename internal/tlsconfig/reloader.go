@@ -0,0 +1,81 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate and key pair from files, reloading them when either
+// file's modification time changes. If a reload fails, for example because only one of
+// the files has been replaced so far, the previous certificate is kept.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// NewCertReloader loads the certificate and key pair in certFile and keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the files if they have changed since they were last loaded.
+func (r *CertReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("cannot read TLS certificate file: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot read TLS key file: %w", err)
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certTime) && keyInfo.ModTime().Equal(r.keyTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load TLS certificate: %w", err)
+	}
+	if r.cert != nil {
+		slog.Info("TLS certificate reloaded", slog.String("cert_file", r.certFile))
+	}
+	r.cert = &cert
+	r.certTime = certInfo.ModTime()
+	r.keyTime = keyInfo.ModTime()
+	return nil
+}
+
+// Certificate returns the current certificate, reloading it first if its files have
+// changed.
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reload(); err != nil {
+		slog.Warn("failed to reload TLS certificate, keeping the previous one",
+			slog.String("cert_file", r.certFile), slog.Any("error", err))
+	}
+	return r.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
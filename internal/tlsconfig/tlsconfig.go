@@ -0,0 +1,74 @@
+// Package tlsconfig builds crypto/tls configurations for the books server and client
+// from config.TLSConfig.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+)
+
+// Server returns a TLS configuration presenting the certificate in conf.CertFile and
+// conf.KeyFile, reloaded whenever the files change. If conf.CAFile is set, clients must
+// present a certificate signed by it.
+func Server(conf config.TLSConfig) (*tls.Config, error) {
+	reloader, err := NewCertReloader(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if conf.CAFile != "" {
+		pool, err := loadCertPool(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// Client returns a TLS configuration verifying the server against conf.CAFile, or the
+// system roots if it is empty. If conf.CertFile is set, the client presents it for
+// mutual TLS, reloaded whenever it changes.
+func Client(conf config.TLSConfig) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: conf.ServerName,
+	}
+	if conf.CAFile != "" {
+		pool, err := loadCertPool(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		c.RootCAs = pool
+	}
+	if conf.CertFile != "" {
+		reloader, err := NewCertReloader(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return c, nil
+}
+
+// loadCertPool returns a pool of the PEM certificates in path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
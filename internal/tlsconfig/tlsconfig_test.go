@@ -0,0 +1,200 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// testCA is a self-signed certificate authority for issuing test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return testCA{cert: cert, key: key, file: file}
+}
+
+// issue writes a certificate for commonName signed by ca, valid for 127.0.0.1, and its
+// key to dir. Returns the certificate and key file paths.
+func (ca testCA) issue(t *testing.T, dir, commonName string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, commonName+".pem")
+	keyFile := filepath.Join(dir, commonName+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	b := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+}
+
+// serveHealth serves the gRPC health service over TLS with conf and returns its address.
+func serveHealth(t *testing.T, conf config.TLSConfig) string {
+	t.Helper()
+	tlsConf, err := Server(conf)
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConf)))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// check calls the health service at addr over TLS with conf.
+func check(t *testing.T, addr string, conf config.TLSConfig) error {
+	t.Helper()
+	tlsConf, err := Client(conf)
+	require.NoError(t, err)
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(credentials.NewTLS(tlsConf)))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestServerAndClient(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.issue(t, dir, "server", 2)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3)
+
+	t.Run("TLS", func(t *testing.T) {
+		r := require.New(t)
+		addr := serveHealth(t, config.TLSConfig{CertFile: serverCert, KeyFile: serverKey})
+		r.NoError(check(t, addr, config.TLSConfig{CAFile: ca.file}))
+	})
+
+	t.Run("untrusted server is rejected", func(t *testing.T) {
+		r := require.New(t)
+		addr := serveHealth(t, config.TLSConfig{CertFile: serverCert, KeyFile: serverKey})
+		r.Error(check(t, addr, config.TLSConfig{CAFile: newTestCA(t).file}))
+	})
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		r := require.New(t)
+		addr := serveHealth(t, config.TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file})
+		r.NoError(check(t, addr, config.TLSConfig{CAFile: ca.file, CertFile: clientCert, KeyFile: clientKey}))
+	})
+
+	t.Run("mutual TLS without client certificate is rejected", func(t *testing.T) {
+		r := require.New(t)
+		addr := serveHealth(t, config.TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file})
+		r.Error(check(t, addr, config.TLSConfig{CAFile: ca.file}))
+	})
+
+	t.Run("mutual TLS with untrusted client certificate is rejected", func(t *testing.T) {
+		r := require.New(t)
+		otherCert, otherKey := newTestCA(t).issue(t, t.TempDir(), "client", 4)
+		addr := serveHealth(t, config.TLSConfig{CertFile: serverCert, KeyFile: serverKey, CAFile: ca.file})
+		r.Error(check(t, addr, config.TLSConfig{CAFile: ca.file, CertFile: otherCert, KeyFile: otherKey}))
+	})
+}
+
+func TestCertReloader(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.issue(t, dir, "server", 10)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	serial := func() int64 {
+		leaf, err := x509.ParseCertificate(reloader.Certificate().Certificate[0])
+		require.NoError(t, err)
+		return leaf.SerialNumber.Int64()
+	}
+	// touch moves the files' modification time forward, as file systems may not record
+	// sub-second changes.
+	touch := func(at time.Time) {
+		require.NoError(t, os.Chtimes(certFile, at, at))
+		require.NoError(t, os.Chtimes(keyFile, at, at))
+	}
+
+	t.Run("unchanged files keep certificate", func(t *testing.T) {
+		require.Equal(t, int64(10), serial())
+	})
+
+	t.Run("replaced files are reloaded", func(t *testing.T) {
+		ca.issue(t, dir, "server", 11)
+		touch(time.Now().Add(time.Minute))
+		require.Equal(t, int64(11), serial())
+	})
+
+	t.Run("invalid replacement keeps previous certificate", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+		touch(time.Now().Add(2 * time.Minute))
+		require.Equal(t, int64(11), serial())
+	})
+
+	t.Run("missing files return error", func(t *testing.T) {
+		_, err := NewCertReloader(filepath.Join(dir, "missing.pem"), keyFile)
+		require.Error(t, err)
+	})
+}
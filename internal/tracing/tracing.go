@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// Setup installs the global OpenTelemetry tracer provider and W3C trace context
+// propagator for conf. The returned function flushes buffered spans and must be called
+// before the process exits.
+//
+// With the "none" exporter only the propagator is installed, so that trace context
+// still flows between services that do export spans.
+func Setup(ctx context.Context, conf config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch conf.Exporter {
+	case config.TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case config.TracingExporterOTLP:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(conf.OTLPEndpoint)}
+		if conf.OTLPInsecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", conf.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s trace exporter: %w", conf.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(conf.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("cannot build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
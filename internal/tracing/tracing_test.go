@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	t.Run("none installs propagator only", func(t *testing.T) {
+		r := require.New(t)
+		conf := config.Default().Tracing
+
+		shutdown, err := Setup(context.Background(), conf)
+		r.NoError(err)
+		r.NoError(shutdown(context.Background()))
+		r.Equal(prev, otel.GetTracerProvider())
+		r.ElementsMatch([]string{"traceparent", "tracestate", "baggage"}, otel.GetTextMapPropagator().Fields())
+	})
+
+	t.Run("stdout installs SDK tracer provider", func(t *testing.T) {
+		r := require.New(t)
+		conf := config.Default().Tracing
+		conf.Exporter = config.TracingExporterStdout
+
+		shutdown, err := Setup(context.Background(), conf)
+		r.NoError(err)
+		r.IsType(&sdktrace.TracerProvider{}, otel.GetTracerProvider())
+		r.NoError(shutdown(context.Background()))
+	})
+
+	t.Run("unknown exporter returns error", func(t *testing.T) {
+		r := require.New(t)
+		conf := config.Default().Tracing
+		conf.Exporter = "zipkin"
+
+		_, err := Setup(context.Background(), conf)
+		r.EqualError(err, `unknown tracing exporter "zipkin"`)
+	})
+}
@@ -0,0 +1,54 @@
+// Package transfer exports books to CSV or JSON Lines files, and imports them back
+// through the books service.
+package transfer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Fields of a book that are exported, and can be mapped to a column on import.
+const (
+	FieldID           = "id"
+	FieldTitle        = "title"
+	FieldAuthor       = "author"
+	FieldCreationTime = "creation_time"
+)
+
+// fields lists every field in the order they are exported.
+var fields = []string{FieldID, FieldTitle, FieldAuthor, FieldCreationTime}
+
+// Columns maps each field of a book to the name of its column in a CSV header, or its
+// key in a JSON object.
+type Columns map[string]string
+
+// DefaultColumns returns the columns that Export writes, named after their fields.
+func DefaultColumns() Columns {
+	cols := make(Columns, len(fields))
+	for _, f := range fields {
+		cols[f] = f
+	}
+	return cols
+}
+
+// ParseColumns parses a mapping such as "title=Book Title,author=Writer" over the
+// default columns, so that unmapped fields keep their own name.
+func ParseColumns(s string) (Columns, error) {
+	cols := DefaultColumns()
+	if s == "" {
+		return cols, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("cannot parse column mapping %q, want field=column", pair)
+		}
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("cannot map unknown field %q, want one of %s", field, strings.Join(fields, ", "))
+		}
+		cols[field] = column
+	}
+	return cols, nil
+}
@@ -0,0 +1,42 @@
+package transfer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColumns(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty mapping returns default columns", func(t *testing.T) {
+		r := require.New(t)
+		cols, err := ParseColumns("")
+		r.NoError(err)
+		r.Equal(DefaultColumns(), cols)
+	})
+
+	t.Run("mapped fields override default columns", func(t *testing.T) {
+		r := require.New(t)
+		cols, err := ParseColumns("title=Book Title, author=Writer")
+		r.NoError(err)
+		r.Equal(Columns{
+			FieldID:           "id",
+			FieldTitle:        "Book Title",
+			FieldAuthor:       "Writer",
+			FieldCreationTime: "creation_time",
+		}, cols)
+	})
+
+	t.Run("unknown field returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := ParseColumns("isbn=ISBN")
+		r.EqualError(err, `cannot map unknown field "isbn", want one of id, title, author, creation_time`)
+	})
+
+	t.Run("missing column returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := ParseColumns("title")
+		r.EqualError(err, `cannot parse column mapping "title", want field=column`)
+	})
+}
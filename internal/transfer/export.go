@@ -0,0 +1,49 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+)
+
+// exportPageSize is the largest page size ListBooks allows.
+const exportPageSize = 50
+
+// Export writes every book to w in creation order, then ID order, reading a page at a time from
+// ListBooks so that the catalogue is never held in memory. Each call to ListBooks is
+// given timeout. Returns the number of books written.
+func Export(ctx context.Context, client books.BooksClient, w Writer, timeout time.Duration) (int, error) {
+	n := 0
+	req := &books.ListBooksRequest{PageSize: exportPageSize}
+	for {
+		res, err := listBooks(ctx, client, req, timeout)
+		if err != nil {
+			return n, fmt.Errorf("failed to list books after %d: %w", n, err)
+		}
+		for _, b := range res.Books {
+			if err := w.Write(b); err != nil {
+				return n, fmt.Errorf("failed to write book %s: %w", b.Id, err)
+			}
+			n++
+		}
+		if res.NextPageToken == "" {
+			break
+		}
+		req.PageToken = res.NextPageToken
+	}
+	if err := w.Flush(); err != nil {
+		return n, fmt.Errorf("failed to write books: %w", err)
+	}
+	return n, nil
+}
+
+// listBooks calls ListBooks with req, giving up after timeout.
+func listBooks(
+	ctx context.Context, client books.BooksClient, req *books.ListBooksRequest, timeout time.Duration,
+) (*books.ListBooksResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return client.ListBooks(ctx, req)
+}
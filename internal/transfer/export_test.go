@@ -0,0 +1,69 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// fakeClient serves ListBooks from books, with page tokens holding the offset of the
+// next page, and ImportBooks with importStream.
+type fakeClient struct {
+	books.BooksClient
+	books        []*books.Book
+	listErr      error
+	importStream *fakeImportStream
+}
+
+func (c *fakeClient) ListBooks(_ context.Context, req *books.ListBooksRequest, _ ...grpc.CallOption) (*books.ListBooksResponse, error) {
+	if c.listErr != nil {
+		return nil, c.listErr
+	}
+	offset, _ := strconv.Atoi(req.PageToken)
+	end := min(offset+int(req.PageSize), len(c.books))
+	res := &books.ListBooksResponse{Books: c.books[offset:end]}
+	if end-offset == int(req.PageSize) {
+		res.NextPageToken = strconv.Itoa(end)
+	}
+	return res, nil
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes every page", func(t *testing.T) {
+		r := require.New(t)
+		client := &fakeClient{}
+		for i := 0; i < 2*exportPageSize; i++ {
+			id := fmt.Sprintf("b%d", i)
+			client.books = append(client.books, &books.Book{Id: id, Title: id, Author: id})
+		}
+		var buf bytes.Buffer
+		w, err := NewWriter(FormatJSONL, &buf)
+		r.NoError(err)
+
+		n, err := Export(context.Background(), client, w, time.Second)
+		r.NoError(err)
+		r.Equal(2*exportPageSize, n)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		r.Len(lines, n)
+		r.JSONEq(`{"id":"b99","title":"b99","author":"b99","creation_time":""}`, lines[99])
+	})
+
+	t.Run("list error is returned", func(t *testing.T) {
+		r := require.New(t)
+		w, err := NewWriter(FormatCSV, &bytes.Buffer{})
+		r.NoError(err)
+		_, err = Export(context.Background(), &fakeClient{listErr: errors.New("unavailable")}, w, time.Second)
+		r.ErrorContains(err, "unavailable")
+	})
+}
@@ -0,0 +1,264 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Format is the file format of an export or import.
+type Format string
+
+const (
+	// FormatCSV has a header naming the columns, followed by a record per book.
+	FormatCSV Format = "csv"
+	// FormatJSONL has a JSON object per line, with a key per column.
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat returns the Format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatJSONL:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, want %q or %q", s, FormatCSV, FormatJSONL)
+}
+
+// Writer writes books to a file in the default columns. Creation times are written in
+// RFC 3339 format, in UTC.
+type Writer interface {
+	Write(b *books.Book) error
+	// Flush writes any buffered books, and returns the first error from writing.
+	Flush() error
+}
+
+// NewWriter returns a Writer of format f to w. A CSV header is written immediately.
+func NewWriter(f Format, w io.Writer) (Writer, error) {
+	switch f {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(fields); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		return csvWriter{cw}, nil
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return jsonlWriter{bw, json.NewEncoder(bw)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// values returns the values of the fields of b, in export order.
+func values(b *books.Book) []string {
+	var creationTime string
+	if b.CreationTime != nil {
+		creationTime = b.CreationTime.AsTime().UTC().Format(time.RFC3339Nano)
+	}
+	return []string{b.Id, b.Title, b.Author, creationTime}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w csvWriter) Write(b *books.Book) error {
+	return w.w.Write(values(b))
+}
+
+func (w csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w jsonlWriter) Write(b *books.Book) error {
+	// The Encoder ends each object with a newline.
+	obj := make(map[string]string, len(fields))
+	for i, v := range values(b) {
+		obj[fields[i]] = v
+	}
+	return w.enc.Encode(obj)
+}
+
+func (w jsonlWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// Row is a record read from a file.
+type Row struct {
+	// Line is the line of the file the record starts on, counting from one.
+	Line int
+	Book *books.Book
+	// Raw is the record as it appears in the file, without the line ending.
+	Raw string
+	// Err is set if the record cannot be read as a book, in which case Book is nil.
+	Err error
+}
+
+// Reader reads books from a file.
+type Reader interface {
+	// Read returns the next record, or io.EOF after the last one. Records that cannot
+	// be read as a book are returned with Row.Err set, and reading can continue after
+	// them.
+	Read() (Row, error)
+}
+
+// NewReader returns a Reader of format f from r, reading each field from its column in
+// cols. The title and author columns are required in a CSV header, and the others are
+// optional.
+func NewReader(f Format, r io.Reader, cols Columns) (Reader, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVReader(r, cols)
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineLength)
+		return &jsonlReader{scanner: scanner, cols: cols}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", f)
+}
+
+// maxLineLength is the longest JSON Lines record that can be read.
+const maxLineLength = 1 << 20
+
+type csvReader struct {
+	r *csv.Reader
+	// index is the position of each field's column in a record, if it has one.
+	index map[string]int
+}
+
+func newCSVReader(r io.Reader, cols Columns) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV file has no header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	index := make(map[string]int, len(fields))
+	for _, field := range fields {
+		for i, column := range header {
+			if strings.TrimSpace(column) == cols[field] {
+				index[field] = i
+				break
+			}
+		}
+	}
+	for _, field := range []string{FieldTitle, FieldAuthor} {
+		if _, ok := index[field]; !ok {
+			return nil, fmt.Errorf("CSV header has no column %q for field %s", cols[field], field)
+		}
+	}
+	return &csvReader{r: cr, index: index}, nil
+}
+
+func (r *csvReader) Read() (Row, error) {
+	record, err := r.r.Read()
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		// The record is only returned for a wrong number of fields.
+		return Row{Line: perr.StartLine, Raw: joinCSV(record), Err: perr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+	row := Row{Line: line, Raw: joinCSV(record)}
+	row.Book, row.Err = newBook(func(field string) (string, bool) {
+		i, ok := r.index[field]
+		if !ok {
+			return "", false
+		}
+		return record[i], true
+	})
+	return row, nil
+}
+
+// joinCSV returns record as a line of CSV.
+func joinCSV(record []string) string {
+	if record == nil {
+		return ""
+	}
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write(record)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	cols    Columns
+	line    int
+}
+
+func (r *jsonlReader) Read() (Row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		raw := r.scanner.Text()
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		row := Row{Line: r.line, Raw: raw}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &obj); err != nil {
+			row.Err = fmt.Errorf("cannot parse JSON object: %w", err)
+			return row, nil
+		}
+		var typeErr error
+		row.Book, row.Err = newBook(func(field string) (string, bool) {
+			v, ok := obj[r.cols[field]]
+			if !ok || string(v) == "null" {
+				return "", false
+			}
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil && typeErr == nil {
+				typeErr = fmt.Errorf("%s must be a string", r.cols[field])
+			}
+			return s, true
+		})
+		if typeErr != nil {
+			row.Book, row.Err = nil, typeErr
+		}
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, fmt.Errorf("failed to read JSON Lines record after line %d: %w", r.line, err)
+	}
+	return Row{}, io.EOF
+}
+
+// newBook returns a book with the fields returned by get. A creation time must be in
+// RFC 3339 format.
+func newBook(get func(field string) (string, bool)) (*books.Book, error) {
+	b := &books.Book{}
+	b.Id, _ = get(FieldID)
+	b.Title, _ = get(FieldTitle)
+	b.Author, _ = get(FieldAuthor)
+	if s, _ := get(FieldCreationTime); s != "" {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse creation time %q as an RFC 3339 time", s)
+		}
+		b.CreationTime = timestamppb.New(t)
+	}
+	return b, nil
+}
@@ -0,0 +1,138 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testBooks returns books with every field set, including one needing CSV quoting.
+func testBooks() []*books.Book {
+	return []*books.Book{
+		{
+			Id:           "b1",
+			Title:        "Dune",
+			Author:       "Frank Herbert",
+			CreationTime: timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		},
+		{
+			Id:           "b2",
+			Title:        "Good Omens, or \"The Nice and Accurate Prophecies\"",
+			Author:       "Terry Pratchett",
+			CreationTime: timestamppb.New(time.Date(2024, 5, 2, 12, 0, 0, 500, time.UTC)),
+		},
+	}
+}
+
+// readAll returns every row read from r.
+func readAll(t *testing.T, r Reader) []Row {
+	t.Helper()
+	var rows []Row
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range []Format{FormatCSV, FormatJSONL} {
+		t.Run(string(f), func(t *testing.T) {
+			r := require.New(t)
+			var buf bytes.Buffer
+			w, err := NewWriter(f, &buf)
+			r.NoError(err)
+			for _, b := range testBooks() {
+				r.NoError(w.Write(b))
+			}
+			r.NoError(w.Flush())
+
+			reader, err := NewReader(f, &buf, DefaultColumns())
+			r.NoError(err)
+			rows := readAll(t, reader)
+			r.Len(rows, 2)
+			for i, row := range rows {
+				r.NoError(row.Err)
+				r.Equal(testBooks()[i].String(), row.Book.String())
+			}
+		})
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reads mapped columns and reports lines", func(t *testing.T) {
+		r := require.New(t)
+		in := "Writer,Book Title,Notes\nFrank Herbert,Dune,\"spans\ntwo lines\"\nTerry Pratchett,Mort,\n"
+		cols, err := ParseColumns("title=Book Title,author=Writer")
+		r.NoError(err)
+		reader, err := NewReader(FormatCSV, strings.NewReader(in), cols)
+		r.NoError(err)
+
+		rows := readAll(t, reader)
+		r.Len(rows, 2)
+		r.Equal(2, rows[0].Line)
+		r.Equal("Dune", rows[0].Book.Title)
+		r.Equal("Frank Herbert", rows[0].Book.Author)
+		r.Empty(rows[0].Book.Id)
+		r.Equal(4, rows[1].Line)
+		r.Equal("Terry Pratchett,Mort,", rows[1].Raw)
+	})
+
+	t.Run("malformed rows are returned with an error", func(t *testing.T) {
+		r := require.New(t)
+		in := "title,author,creation_time\nDune\nMort,Terry Pratchett,yesterday\nEric,Terry Pratchett,\n"
+		reader, err := NewReader(FormatCSV, strings.NewReader(in), DefaultColumns())
+		r.NoError(err)
+
+		rows := readAll(t, reader)
+		r.Len(rows, 3)
+		r.Equal(2, rows[0].Line)
+		r.Equal("Dune", rows[0].Raw)
+		r.ErrorContains(rows[0].Err, "wrong number of fields")
+		r.EqualError(rows[1].Err, `cannot parse creation time "yesterday" as an RFC 3339 time`)
+		r.NoError(rows[2].Err)
+		r.Equal("Eric", rows[2].Book.Title)
+	})
+
+	t.Run("header without title column returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := NewReader(FormatCSV, strings.NewReader("id,author\n"), DefaultColumns())
+		r.EqualError(err, `CSV header has no column "title" for field title`)
+	})
+}
+
+func TestJSONLReader(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+	in := `{"name":"Dune","writer":"Frank Herbert"}` + "\n\n" +
+		`{"name":"Mort",` + "\n" +
+		`{"name":7,"writer":"Terry Pratchett"}` + "\n"
+	cols, err := ParseColumns("title=name,author=writer")
+	r.NoError(err)
+	reader, err := NewReader(FormatJSONL, strings.NewReader(in), cols)
+	r.NoError(err)
+
+	rows := readAll(t, reader)
+	r.Len(rows, 3)
+	r.Equal(1, rows[0].Line)
+	r.Equal("Dune", rows[0].Book.Title)
+	r.Equal("Frank Herbert", rows[0].Book.Author)
+	r.Equal(3, rows[1].Line)
+	r.ErrorContains(rows[1].Err, "cannot parse JSON object")
+	r.Equal(4, rows[2].Line)
+	r.EqualError(rows[2].Err, "name must be a string")
+}
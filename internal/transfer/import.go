@@ -0,0 +1,226 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/services/booksservice"
+)
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// ImportID names the import on the server, so that an interrupted import can be
+	// resumed by running it again with the same ID and file.
+	ImportID string
+	// DryRun only validates the rows, without calling the server.
+	DryRun bool
+	// Progress, if set, is called with each progress report from the server.
+	Progress func(*books.ImportBooksResponse)
+}
+
+// Summary counts the rows of an import. The server's counts cover every run of a
+// resumed import.
+type Summary struct {
+	Rows              int
+	Inserted          int64
+	SkippedDuplicates int64
+	Rejected          int
+}
+
+// Rejection is a row that was not imported.
+type Rejection struct {
+	Line   int
+	Reason string
+	// Raw is the row as it appears in the file, if known.
+	Raw string
+}
+
+// Report writes rejected rows as CSV, with the columns line, reason and record.
+type Report struct {
+	w *csv.Writer
+}
+
+// NewReport returns a Report to w, writing its header immediately.
+func NewReport(w io.Writer) (*Report, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "reason", "record"}); err != nil {
+		return nil, fmt.Errorf("failed to write report header: %w", err)
+	}
+	return &Report{cw}, nil
+}
+
+// Add writes rej to the report.
+func (r *Report) Add(rej Rejection) error {
+	return r.w.Write([]string{strconv.Itoa(rej.Line), rej.Reason, rej.Raw})
+}
+
+// Flush writes any buffered rejections, and returns the first error from writing.
+func (r *Report) Flush() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// Validate returns an error if row cannot be read as a book, or the book would be
+// rejected by the books service.
+func Validate(row Row, importID string) error {
+	if row.Err != nil {
+		return row.Err
+	}
+	// The import ID stands in for the request ID, as it does on the server.
+	return booksservice.ValidateCreateBookRequest(&books.CreateBookRequest{Book: row.Book, RequestId: importID})
+}
+
+// Import reads rows and imports the valid ones with ImportBooks, adding every rejected
+// row to report, whether it failed validation here or on the server. Rows the server
+// has already committed for the import are not sent again. With DryRun set, rows are
+// only validated. The report is flushed however Import returns, so that it lists the
+// rows rejected before any error.
+func Import(
+	ctx context.Context, client books.BooksClient, rows Reader, opts ImportOptions, report *Report,
+) (_ Summary, err error) {
+	defer func() {
+		if flushErr := report.Flush(); flushErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to write report: %w", flushErr))
+		}
+	}()
+
+	if err := booksservice.ValidateImportBooksRequest(&books.ImportBooksRequest{ImportId: opts.ImportID}); err != nil {
+		return Summary{}, fmt.Errorf("invalid import ID: %w", err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stream books.Books_ImportBooksClient
+	var committed int64
+	if !opts.DryRun {
+		var err error
+		if stream, committed, err = startImport(ctx, client, opts); err != nil {
+			return Summary{}, err
+		}
+	}
+	// Progress is received concurrently, so that the server is never held up sending it.
+	results := make(chan importResult, 1)
+	if stream != nil {
+		go func() {
+			results <- receiveSummary(stream, opts.Progress)
+		}()
+	}
+
+	var sum Summary
+	// lines holds the line of each row sent, by offset.
+	var lines []int
+	var sendErr error
+	for {
+		row, err := rows.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return sum, fmt.Errorf("failed to read row %d: %w", sum.Rows+1, err)
+		}
+		sum.Rows++
+
+		if err := Validate(row, opts.ImportID); err != nil {
+			sum.Rejected++
+			if err := report.Add(Rejection{Line: row.Line, Reason: err.Error(), Raw: row.Raw}); err != nil {
+				return sum, fmt.Errorf("failed to write report: %w", err)
+			}
+			continue
+		}
+		offset := int64(len(lines))
+		lines = append(lines, row.Line)
+		if stream == nil || offset < committed || sendErr != nil {
+			continue
+		}
+		// The server's error, if it ended the stream, is returned by receiveSummary.
+		sendErr = stream.Send(&books.ImportBooksRequest{Offset: offset, Book: row.Book})
+	}
+
+	if stream != nil {
+		if sendErr == nil {
+			sendErr = stream.CloseSend()
+		}
+		res := <-results
+		if res.err != nil {
+			return sum, fmt.Errorf("failed to import books: %w", res.err)
+		}
+		if sendErr != nil {
+			return sum, fmt.Errorf("failed to send books: %w", sendErr)
+		}
+		sum.Inserted = res.summary.Inserted
+		sum.SkippedDuplicates = res.summary.SkippedDuplicates
+		for _, rej := range res.summary.Rejections {
+			sum.Rejected++
+			line := 0
+			if rej.Offset >= 0 && rej.Offset < int64(len(lines)) {
+				line = lines[rej.Offset]
+			}
+			if err := report.Add(Rejection{Line: line, Reason: rej.Reason}); err != nil {
+				return sum, fmt.Errorf("failed to write report: %w", err)
+			}
+		}
+	}
+	return sum, nil
+}
+
+// startImport opens an ImportBooks stream for the import, and returns it with the
+// number of rows the server has already committed.
+func startImport(
+	ctx context.Context, client books.BooksClient, opts ImportOptions,
+) (books.Books_ImportBooksClient, int64, error) {
+	stream, err := client.ImportBooks(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to start import: %w", err)
+	}
+	if err := stream.Send(&books.ImportBooksRequest{ImportId: opts.ImportID}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, fmt.Errorf("failed to start import: %w", err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to start import: %w", err)
+	}
+	if opts.Progress != nil {
+		opts.Progress(res)
+	}
+	return stream, res.Committed, nil
+}
+
+type importResult struct {
+	summary *books.ImportBooksResponse
+	err     error
+}
+
+// receiveSummary receives responses from stream until it ends, passing each to progress
+// if set. The summary's rejections, which may span several responses, are collected into
+// one.
+func receiveSummary(stream books.Books_ImportBooksClient, progress func(*books.ImportBooksResponse)) importResult {
+	var summary *books.ImportBooksResponse
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			if summary == nil {
+				return importResult{err: errors.New("stream ended without a summary")}
+			}
+			return importResult{summary: summary}
+		}
+		if err != nil {
+			return importResult{err: err}
+		}
+		if progress != nil {
+			progress(res)
+		}
+		if !res.Done {
+			continue
+		}
+		if summary == nil {
+			summary = res
+			continue
+		}
+		summary.Rejections = append(summary.Rejections, res.Rejections...)
+	}
+}
@@ -0,0 +1,165 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	books "github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/services/booksservice"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func (c *fakeClient) ImportBooks(context.Context, ...grpc.CallOption) (books.Books_ImportBooksClient, error) {
+	return c.importStream, nil
+}
+
+// fakeImportStream acts as a server that has already committed committed records, and
+// inserts every record sent after them. Its summary rejects offsets 1 and 2, one per
+// response.
+type fakeImportStream struct {
+	grpc.ClientStream
+	committed int64
+	sent      []*books.ImportBooksRequest
+	closed    chan struct{}
+	responses int
+}
+
+func newFakeImportStream(committed int64) *fakeImportStream {
+	return &fakeImportStream{committed: committed, closed: make(chan struct{})}
+}
+
+func (s *fakeImportStream) Send(req *books.ImportBooksRequest) error {
+	s.sent = append(s.sent, req)
+	return nil
+}
+
+func (s *fakeImportStream) CloseSend() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *fakeImportStream) Recv() (*books.ImportBooksResponse, error) {
+	s.responses++
+	switch s.responses {
+	case 1:
+		return &books.ImportBooksResponse{Committed: s.committed}, nil
+	case 2:
+		<-s.closed
+		records := int64(len(s.sent) - 1)
+		return &books.ImportBooksResponse{
+			Committed:  s.committed + records,
+			Inserted:   s.committed + records,
+			Rejections: []*books.ImportRejection{{Offset: 1, Reason: "rejected by server"}},
+			Done:       true,
+		}, nil
+	case 3:
+		return &books.ImportBooksResponse{
+			Rejections: []*books.ImportRejection{{Offset: 2, Reason: "also rejected by server"}},
+			Done:       true,
+		}, nil
+	}
+	return nil, io.EOF
+}
+
+// failingReader reads the rows of Reader, then fails instead of ending.
+type failingReader struct {
+	Reader
+}
+
+func (r failingReader) Read() (Row, error) {
+	row, err := r.Reader.Read()
+	if errors.Is(err, io.EOF) {
+		return Row{}, errors.New("disk failed")
+	}
+	return row, err
+}
+
+// testCSV has valid books on lines 2, 4 and 5, and a book without an author on line 3.
+const testCSV = "title,author\nDune,Frank Herbert\nMort,\nEric,Terry Pratchett\nSourcery,Terry Pratchett\n"
+
+// readReport returns the records of report, without the header.
+func readReport(t *testing.T, report *bytes.Buffer) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(report).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"line", "reason", "record"}, records[0])
+	return records[1:]
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dry run reports invalid rows", func(t *testing.T) {
+		r := require.New(t)
+		rows, err := NewReader(FormatCSV, strings.NewReader(testCSV), DefaultColumns())
+		r.NoError(err)
+		var buf bytes.Buffer
+		report, err := NewReport(&buf)
+		r.NoError(err)
+
+		sum, err := Import(context.Background(), nil, rows, ImportOptions{ImportID: "import-1", DryRun: true}, report)
+		r.NoError(err)
+		r.Equal(Summary{Rows: 4, Rejected: 1}, sum)
+		expectedErr := booksservice.ValidationError{Field: "author", Message: "must not be empty"}
+		r.Equal([][]string{{"3", expectedErr.Error(), "Mort,"}}, readReport(t, &buf))
+	})
+
+	t.Run("sends valid rows after those committed, and reports every summary page", func(t *testing.T) {
+		r := require.New(t)
+		rows, err := NewReader(FormatCSV, strings.NewReader(testCSV), DefaultColumns())
+		r.NoError(err)
+		var buf bytes.Buffer
+		report, err := NewReport(&buf)
+		r.NoError(err)
+		stream := newFakeImportStream(1)
+
+		sum, err := Import(context.Background(), &fakeClient{importStream: stream}, rows, ImportOptions{ImportID: "import-1"}, report)
+		r.NoError(err)
+		r.Equal(Summary{Rows: 4, Inserted: 3, Rejected: 3}, sum)
+
+		r.Len(stream.sent, 3)
+		r.Equal("import-1", stream.sent[0].ImportId)
+		r.Nil(stream.sent[0].Book)
+		r.EqualValues(1, stream.sent[1].Offset)
+		r.Equal("Eric", stream.sent[1].Book.Title)
+		r.EqualValues(2, stream.sent[2].Offset)
+		r.Equal("Sourcery", stream.sent[2].Book.Title)
+
+		records := readReport(t, &buf)
+		r.Len(records, 3)
+		r.Equal("3", records[0][0])
+		r.Equal([]string{"4", "rejected by server", ""}, records[1])
+		r.Equal([]string{"5", "also rejected by server", ""}, records[2])
+	})
+
+	t.Run("report is flushed on error", func(t *testing.T) {
+		r := require.New(t)
+		rows, err := NewReader(FormatCSV, strings.NewReader(testCSV), DefaultColumns())
+		r.NoError(err)
+		var buf bytes.Buffer
+		report, err := NewReport(&buf)
+		r.NoError(err)
+
+		_, err = Import(context.Background(), nil, failingReader{rows}, ImportOptions{ImportID: "import-1", DryRun: true}, report)
+		r.ErrorContains(err, "disk failed")
+		records := readReport(t, &buf)
+		r.Len(records, 1)
+		r.Equal("3", records[0][0])
+	})
+
+	t.Run("invalid import ID returns error", func(t *testing.T) {
+		r := require.New(t)
+		rows, err := NewReader(FormatCSV, strings.NewReader(testCSV), DefaultColumns())
+		r.NoError(err)
+		report, err := NewReport(&bytes.Buffer{})
+		r.NoError(err)
+		_, err = Import(context.Background(), nil, rows, ImportOptions{DryRun: true}, report)
+		r.ErrorContains(err, "invalid import ID")
+	})
+}
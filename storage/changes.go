@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pruneBatchSize is the most changes PruneBookChanges deletes per statement.
+const pruneBatchSize = 1000
+
+// ChangeType is the kind of change recorded in the 'book_changes' change log.
+type ChangeType string
+
+const (
+	ChangeCreated ChangeType = "CREATED"
+	ChangeUpdated ChangeType = "UPDATED"
+	ChangeDeleted ChangeType = "DELETED"
+)
+
+// BookChange is a record of the 'book_changes' change log. Sequence numbers increase
+// in the order that changes are committed.
+type BookChange struct {
+	Sequence   uint64
+	Type       ChangeType
+	ChangeTime time.Time
+
+	// Book is the book after the change, or as it was before a deletion.
+	Book Book
+}
+
+// recordChanges appends a change of type t to each of bs to the change log within tx,
+// so that they are committed together with the changes themselves, and writes outbox
+// events for them if the outbox is enabled.
+func (s *MysqlStorage) recordChanges(ctx context.Context, tx *sql.Tx, t ChangeType, bs []*Book) error {
+	// LAST_INSERT_ID(expr) makes the incremented sequence number available to this
+	// connection without a further query. The row stays locked until tx ends.
+	res, err := tx.ExecContext(ctx,
+		"UPDATE `book_change_sequence` SET `sequence` = LAST_INSERT_ID(`sequence` + ?) WHERE `id` = 1;", len(bs))
+	if err != nil {
+		return fmt.Errorf("failed to allocate change sequence numbers: %w", err)
+	}
+	last, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read change sequence number: %w", err)
+	}
+
+	changeTime := time.Now().UTC()
+	first := uint64(last) - uint64(len(bs)) + 1
+	args := make([]any, 0, 7*len(bs))
+	for i, b := range bs {
+		args = append(args, first+uint64(i), t, changeTime, b.Id, b.Title, b.Author, b.CreationTime)
+	}
+	query := "INSERT INTO `book_changes` (`sequence`, `change_type`, `change_time`, `book_id`, `title`, `author`, `creation_time`) VALUES " +
+		valuesPlaceholders(len(bs), 7) + ";"
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to record changes: %w", err)
+	}
+
+	if s.outbox {
+		return enqueueEvents(ctx, tx, t, bs, changeTime)
+	}
+	return nil
+}
+
+// valuesPlaceholders returns the placeholders for rows rows of cols values each, such as
+// "(?, ?), (?, ?)".
+func valuesPlaceholders(rows, cols int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", cols), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+", ", rows), ", ")
+}
+
+// ListBookChanges returns up to limit changes with a sequence number greater than
+// after, in sequence order.
+func (s *MysqlStorage) ListBookChanges(ctx context.Context, after uint64, limit int) (_ []BookChange, err error) {
+	query := `SELECT sequence, change_type, change_time, book_id, title, author, creation_time
+	FROM book_changes
+	WHERE sequence > ?
+	ORDER BY sequence ASC
+	LIMIT ?;
+	`
+	ctx, end := s.startOperation(ctx, "ListBookChanges", query)
+	defer end(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []BookChange
+	for rows.Next() {
+		var c BookChange
+		if err := rows.Scan(
+			&c.Sequence, &c.Type, &c.ChangeTime, &c.Book.Id, &c.Book.Title, &c.Book.Author, &c.Book.CreationTime,
+		); err != nil {
+			return nil, fmt.Errorf("failed to parse row into BookChange: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered when iterating over rows: %w", err)
+	}
+
+	return changes, nil
+}
+
+// BookChangeRange returns the sequence numbers of the last change pruned from the
+// change log and of the last change committed to it. Either is zero if there is none.
+func (s *MysqlStorage) BookChangeRange(ctx context.Context) (pruned, latest uint64, err error) {
+	query := `SELECT r.pruned_sequence, s.sequence
+	FROM book_change_retention r, book_change_sequence s
+	WHERE r.id = 1 AND s.id = 1;
+	`
+	ctx, end := s.startOperation(ctx, "BookChangeRange", query)
+	defer end(&err)
+
+	if err := s.db.QueryRowContext(ctx, query).Scan(&pruned, &latest); err != nil {
+		return 0, 0, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	return pruned, latest, nil
+}
+
+// PruneBookChanges deletes the changes committed before before from the change log, and
+// returns how many were deleted. The last pruned sequence number is raised before the
+// changes are deleted, so that readers checking it find out that changes after their
+// cursor are gone rather than skipping them.
+func (s *MysqlStorage) PruneBookChanges(ctx context.Context, before time.Time) (deleted int64, err error) {
+	query := "SELECT `sequence` FROM `book_changes` WHERE `change_time` >= ? ORDER BY `sequence` ASC LIMIT 1;"
+	ctx, end := s.startOperation(ctx, "PruneBookChanges", query)
+	defer end(&err)
+
+	// Change times are taken while the sequence row is locked, so they increase with
+	// the sequence number, and this reads only the changes to prune and the first one to
+	// keep.
+	var last, keep uint64
+	err = s.db.QueryRowContext(ctx, query, before.UTC()).Scan(&keep)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(`sequence`), 0) FROM `book_changes`;").Scan(&last)
+		if err != nil {
+			return 0, fmt.Errorf("failed perform SQL query: %w", err)
+		}
+	case err != nil:
+		return 0, fmt.Errorf("failed perform SQL query: %w", err)
+	default:
+		last = keep - 1
+	}
+	if last == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE `book_change_retention` SET `pruned_sequence` = GREATEST(`pruned_sequence`, ?) WHERE `id` = 1;", last,
+	); err != nil {
+		return 0, fmt.Errorf("failed to record pruned changes: %w", err)
+	}
+
+	// Deleting in batches keeps each statement's locks short, as writers appending to
+	// the change log may wait on them.
+	for {
+		res, err := s.db.ExecContext(ctx,
+			"DELETE FROM `book_changes` WHERE `sequence` <= ? ORDER BY `sequence` ASC LIMIT ?;", last, pruneBatchSize)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to prune changes: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to read pruned change count: %w", err)
+		}
+		deleted += n
+		if n < pruneBatchSize {
+			return deleted, nil
+		}
+	}
+}
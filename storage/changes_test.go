@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesPlaceholders(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	r.Equal("(?)", valuesPlaceholders(1, 1))
+	r.Equal("(?, ?, ?)", valuesPlaceholders(1, 3))
+	r.Equal("(?, ?), (?, ?), (?, ?)", valuesPlaceholders(3, 2))
+}
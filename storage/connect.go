@@ -1,43 +1,171 @@
 package storage
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql" // blank import runs init
+	"github.com/go-sql-driver/mysql"
 )
 
 type MysqlStorage struct {
-	db *sql.DB
+	db       *sql.DB
+	dbName   string
+	observer QueryObserver
+	outbox   bool
 }
 
+// QueryObserver is notified of the duration and outcome of every storage operation,
+// for example to record metrics. Operations are named after the MysqlStorage method.
+type QueryObserver interface {
+	ObserveQuery(operation string, duration time.Duration, err error)
+}
+
+// MysqlConfig holds the connection, driver and pool settings for a MySQL database. Zero
+// durations and pool sizes keep the driver and database/sql defaults.
 type MysqlConfig struct {
-	Username string
-	Password string
-	DBName   string
-	Port     uint
-	Host     string
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"dbname"`
+	Port     uint   `yaml:"port"`
+	Host     string `yaml:"host"`
+
+	// Timeout limits establishing a connection, and ReadTimeout and WriteTimeout limit
+	// each I/O operation on it.
+	Timeout      time.Duration `yaml:"timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	Charset      string        `yaml:"charset"`
+	Collation    string        `yaml:"collation"`
+
+	// TLS is one of "false" (or empty), "true", "skip-verify" or "preferred", as
+	// accepted by the mysql driver. With "true", the server is verified against
+	// TLSCAFile if set, or the system roots otherwise.
+	TLS       string `yaml:"tls"`
+	TLSCAFile string `yaml:"tls_ca_file"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
 }
 
 // NewMysqlStorage opens a new connection to the DB via the mysql driver.
 func NewMysqlStorage(conf MysqlConfig) (MysqlStorage, error) {
-	// username:password@protocol(address)/dbname?param=value
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", conf.Username, conf.Password, conf.Host, conf.Port, conf.DBName)
-	db, err := sql.Open("mysql", dsn)
+	driverConf, err := conf.driverConfig()
+	if err != nil {
+		return MysqlStorage{}, err
+	}
+
+	connector, err := mysql.NewConnector(driverConf)
 	if err != nil {
 		return MysqlStorage{}, fmt.Errorf("cannot validate open MySQL database connection arguments: %w", err)
 	}
+	db := sql.OpenDB(connector)
+
+	if conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+	}
+	if conf.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(conf.MaxIdleConns)
+	}
+	if conf.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	}
+	if conf.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+	}
 
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return MysqlStorage{}, fmt.Errorf("cannot open MySQL database connection: %w", err)
 	}
 
-	mysqlStorage := MysqlStorage{
-		db: db,
+	slog.Info("MySQL database connection created",
+		slog.String("user", driverConf.User),
+		slog.String("addr", driverConf.Addr),
+		slog.String("dbname", driverConf.DBName),
+	)
+
+	return MysqlStorage{
+		db:     db,
+		dbName: driverConf.DBName,
+	}, nil
+}
+
+// NewMysqlStorageFromDB returns a MysqlStorage using db, which it closes when closed.
+// Unlike NewMysqlStorage, it does not check that the database is reachable.
+func NewMysqlStorageFromDB(db *sql.DB) MysqlStorage {
+	return MysqlStorage{db: db}
+}
+
+// driverConfig translates conf into the mysql driver configuration. Time values are
+// always parsed into time.Time in UTC, which the queries in this package rely on.
+func (conf MysqlConfig) driverConfig() (*mysql.Config, error) {
+	c := mysql.NewConfig()
+	c.User = conf.Username
+	c.Passwd = conf.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(conf.Host, strconv.FormatUint(uint64(conf.Port), 10))
+	c.DBName = conf.DBName
+	c.ParseTime = true
+	c.Loc = time.UTC
+	c.Timeout = conf.Timeout
+	c.ReadTimeout = conf.ReadTimeout
+	c.WriteTimeout = conf.WriteTimeout
+	if conf.Collation != "" {
+		c.Collation = conf.Collation
+	}
+	if conf.Charset != "" {
+		c.Params = map[string]string{"charset": conf.Charset}
 	}
-	log.Printf("MySQL database connection created: %v", mysqlStorage)
 
-	return mysqlStorage, nil
+	c.TLSConfig = conf.TLS
+	if conf.TLSCAFile != "" {
+		if conf.TLS != "true" {
+			return nil, fmt.Errorf(`MySQL TLS CA file requires TLS mode "true", got %q`, conf.TLS)
+		}
+		pem, err := os.ReadFile(conf.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read MySQL TLS CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in MySQL TLS CA file %s", conf.TLSCAFile)
+		}
+		c.TLS = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	return c, nil
+}
+
+// Close closes the database and its connection pool, waiting for in-flight queries
+// to finish.
+func (s *MysqlStorage) Close() error {
+	return s.db.Close()
+}
+
+// Ping verifies that the database is still reachable, opening a connection if needed.
+func (s *MysqlStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// SetQueryObserver registers o to be notified of every storage operation. It must be
+// called before the storage is used concurrently.
+func (s *MysqlStorage) SetQueryObserver(o QueryObserver) {
+	s.observer = o
+}
+
+// Stats returns connection pool statistics, such as open and in-use connections and
+// time spent waiting for a free connection.
+func (s *MysqlStorage) Stats() sql.DBStats {
+	return s.db.Stats()
 }
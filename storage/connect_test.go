@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriverConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("maps connection settings and parameters", func(t *testing.T) {
+		r := require.New(t)
+		c, err := MysqlConfig{
+			Username:     "user1",
+			Password:     "p@ss:word/",
+			DBName:       "library",
+			Port:         3306,
+			Host:         "db.internal",
+			Timeout:      time.Second,
+			ReadTimeout:  2 * time.Second,
+			WriteTimeout: 3 * time.Second,
+			Charset:      "utf8mb4",
+			Collation:    "utf8mb4_unicode_ci",
+			TLS:          "skip-verify",
+		}.driverConfig()
+		r.NoError(err)
+
+		r.Equal("db.internal:3306", c.Addr)
+		r.Equal("p@ss:word/", c.Passwd)
+		r.True(c.ParseTime)
+		r.Equal(time.UTC, c.Loc)
+		r.Equal(time.Second, c.Timeout)
+		r.Equal(2*time.Second, c.ReadTimeout)
+		r.Equal(3*time.Second, c.WriteTimeout)
+		r.Equal(map[string]string{"charset": "utf8mb4"}, c.Params)
+		r.Equal("utf8mb4_unicode_ci", c.Collation)
+		r.Equal("skip-verify", c.TLSConfig)
+		r.Contains(c.FormatDSN(), "parseTime=true")
+	})
+
+	t.Run("IPv6 host is bracketed", func(t *testing.T) {
+		r := require.New(t)
+		c, err := MysqlConfig{Host: "::1", Port: 3306}.driverConfig()
+		r.NoError(err)
+		r.Equal("[::1]:3306", c.Addr)
+	})
+
+	t.Run("CA file without TLS mode true returns error", func(t *testing.T) {
+		r := require.New(t)
+		_, err := MysqlConfig{TLS: "preferred", TLSCAFile: "ca.pem"}.driverConfig()
+		r.EqualError(err, `MySQL TLS CA file requires TLS mode "true", got "preferred"`)
+	})
+
+	t.Run("CA file without certificates returns error", func(t *testing.T) {
+		r := require.New(t)
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		r.NoError(os.WriteFile(path, []byte("not a certificate"), 0o600))
+		_, err := MysqlConfig{TLS: "true", TLSCAFile: path}.driverConfig()
+		r.ErrorContains(err, "no certificates found")
+	})
+}
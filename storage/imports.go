@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Import is a record of the 'imports' table: the progress of an import.
+type Import struct {
+	ID string
+
+	// Committed is the number of records in the chunks committed so far, which is the
+	// offset of the next record to import.
+	Committed int64
+	Inserted  int64
+	// SkippedDuplicates counts the valid records for a book ID that already exists.
+	SkippedDuplicates int64
+	Rejected          int64
+}
+
+// ImportRejection is a record of an import that failed validation.
+type ImportRejection struct {
+	Offset int64
+	Reason string
+}
+
+// ImportChunk is a run of consecutive records of an import, from offset Start up to but
+// excluding End.
+type ImportChunk struct {
+	ImportID   string
+	Start, End int64
+
+	// Books are the valid records of the chunk.
+	Books []*Book
+	// Rejections are the invalid records of the chunk.
+	Rejections []ImportRejection
+}
+
+// ErrImportConflict is returned by CommitImportChunk when a chunk does not start at the
+// import's committed count, because another stream for the import has committed records
+// since the chunk started.
+var ErrImportConflict = errors.New("import has committed records since the chunk started")
+
+// GetImport returns the progress of the import with importID. If the import has not
+// committed any records, only the ID of the progress is set.
+func (s *MysqlStorage) GetImport(ctx context.Context, importID string) (imp Import, err error) {
+	query := "SELECT committed, inserted, skipped_duplicates, rejected FROM imports WHERE import_id = ?;"
+	ctx, end := s.startOperation(ctx, "GetImport", query)
+	defer end(&err)
+
+	imp, err = scanImport(s.db.QueryRowContext(ctx, query, importID), importID)
+	if err != nil {
+		return Import{}, err
+	}
+	return imp, nil
+}
+
+// CommitImportChunk inserts the books of c, records its rejections and updates the
+// progress of the import in one transaction, so that a chunk is either committed whole
+// or not at all. Books whose ID is already in the 'books' table, or earlier in c, are
+// skipped as duplicates. Returns the updated progress,
+// or ErrImportConflict if c does not start at the import's committed count. Like
+// CreateBooks, the transaction is retried if a book is created concurrently.
+func (s *MysqlStorage) CommitImportChunk(ctx context.Context, c ImportChunk) (imp Import, err error) {
+	query := `UPDATE imports
+	SET committed = ?, inserted = ?, skipped_duplicates = ?, rejected = ?, update_time = ?
+	WHERE import_id = ?;
+	`
+	ctx, end := s.startOperation(ctx, "CommitImportChunk", query)
+	defer end(&err)
+
+	err = s.retryTx(ctx, func(tx *sql.Tx) error {
+		// Locking the import's row makes concurrent streams for an import commit one at a
+		// time, so that the check against Start holds until the transaction ends. The
+		// row is created first, as locking a missing row would only lock the gap, which
+		// concurrent streams could all hold before deadlocking on the insert.
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO `imports` (`import_id`, `committed`, `inserted`, `skipped_duplicates`, `rejected`, `update_time`) "+
+				"VALUES (?, 0, 0, 0, 0, ?) ON DUPLICATE KEY UPDATE `import_id` = `import_id`;",
+			c.ImportID, time.Now().UTC(),
+		); err != nil {
+			return fmt.Errorf("failed to create import: %w", err)
+		}
+		imp, err = scanImport(tx.QueryRowContext(ctx,
+			"SELECT committed, inserted, skipped_duplicates, rejected FROM imports WHERE import_id = ? FOR UPDATE;",
+			c.ImportID,
+		), c.ImportID)
+		if err != nil {
+			return err
+		}
+		if imp.Committed != c.Start {
+			return ErrImportConflict
+		}
+
+		seen := make(map[string]bool, len(c.Books))
+		var bs []*Book
+		for _, b := range c.Books {
+			if seen[b.Id] {
+				imp.SkippedDuplicates++
+				continue
+			}
+			seen[b.Id] = true
+			bs = append(bs, b)
+		}
+		if len(bs) > 0 {
+			existing, err := s.insertBooks(ctx, tx, bs, true)
+			if err != nil {
+				return err
+			}
+			imp.Inserted += int64(len(bs) - len(existing))
+			imp.SkippedDuplicates += int64(len(existing))
+		}
+
+		if len(c.Rejections) > 0 {
+			args := make([]any, 0, 3*len(c.Rejections))
+			for _, rej := range c.Rejections {
+				args = append(args, c.ImportID, rej.Offset, rej.Reason)
+			}
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO `import_rejections` (`import_id`, `record_offset`, `reason`) VALUES "+
+					valuesPlaceholders(len(c.Rejections), 3)+";", args...,
+			); err != nil {
+				return fmt.Errorf("failed to record rejections: %w", err)
+			}
+			imp.Rejected += int64(len(c.Rejections))
+		}
+
+		imp.Committed = c.End
+		if _, err := tx.ExecContext(ctx, query,
+			imp.Committed, imp.Inserted, imp.SkippedDuplicates, imp.Rejected, time.Now().UTC(), imp.ID,
+		); err != nil {
+			return fmt.Errorf("failed perform SQL query: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return Import{}, err
+	}
+	return imp, nil
+}
+
+// scanImport reads the progress of the import with importID from row, which is zero if
+// the row is missing.
+func scanImport(row *sql.Row, importID string) (Import, error) {
+	imp := Import{ID: importID}
+	err := row.Scan(&imp.Committed, &imp.Inserted, &imp.SkippedDuplicates, &imp.Rejected)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Import{}, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	return imp, nil
+}
+
+// ListImportRejections returns up to limit rejected records of the import with importID
+// at an offset greater than after, in offset order.
+func (s *MysqlStorage) ListImportRejections(
+	ctx context.Context, importID string, after int64, limit int,
+) (_ []ImportRejection, err error) {
+	query := `SELECT record_offset, reason
+	FROM import_rejections
+	WHERE import_id = ? AND record_offset > ?
+	ORDER BY record_offset ASC
+	LIMIT ?;
+	`
+	ctx, end := s.startOperation(ctx, "ListImportRejections", query)
+	defer end(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, importID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	defer rows.Close()
+
+	var rejections []ImportRejection
+	for rows.Next() {
+		var rej ImportRejection
+		if err := rows.Scan(&rej.Offset, &rej.Reason); err != nil {
+			return nil, fmt.Errorf("failed to parse row into ImportRejection: %w", err)
+		}
+		rejections = append(rejections, rej)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered when iterating over rows: %w", err)
+	}
+
+	return rejections, nil
+}
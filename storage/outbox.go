@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/oklog/ulid/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// OutboxEvent is a record of the 'outbox' table: an event about a change to a book,
+// waiting to be published to other systems.
+type OutboxEvent struct {
+	Sequence uint64
+
+	// ID is unique to the event, and is kept when the event is published more than
+	// once, so that consumers can discard duplicates.
+	ID string
+	// Type is "book.created", "book.updated" or "book.deleted".
+	Type string
+	// Key is the ID of the book, which orders events about the same book in sinks that
+	// partition by key.
+	Key  string
+	Time time.Time
+	// Payload is the book as JSON, after the change or as it was before a deletion.
+	Payload []byte
+}
+
+// EnableOutbox makes mutations write an outbox event alongside each change log record.
+// It must be called before the storage is used concurrently.
+func (s *MysqlStorage) EnableOutbox() {
+	s.outbox = true
+}
+
+// enqueueEvents writes an outbox event for a change of type t to each of bs within tx.
+func enqueueEvents(ctx context.Context, tx *sql.Tx, t ChangeType, bs []*Book, changeTime time.Time) error {
+	eventType := "book." + strings.ToLower(string(t))
+	args := make([]any, 0, 5*len(bs))
+	for _, b := range bs {
+		payload, err := protojson.Marshal(&books.Book{
+			Id:           b.Id,
+			Title:        b.Title,
+			Author:       b.Author,
+			CreationTime: timestamppb.New(b.CreationTime),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode event payload: %w", err)
+		}
+		args = append(args, ulid.Make().String(), eventType, b.Id, changeTime, payload)
+	}
+
+	query := "INSERT INTO `outbox` (`event_id`, `event_type`, `event_key`, `event_time`, `payload`) VALUES " +
+		valuesPlaceholders(len(bs), 5) + ";"
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to write outbox events: %w", err)
+	}
+	return nil
+}
+
+// PublishOutbox reads up to limit outbox events, oldest first, and passes them to
+// publish in order until it returns an error. The events published are deleted from
+// the outbox. Relays sharing an outbox take turns by locking the row of the
+// 'outbox_lease' table, so that a later event is never published before an earlier
+// one. A concurrent PublishOutbox waits until this one ends. The events are read
+// without locks, so writers adding events are not held up while they are published.
+// Events are read in the order they are committed, as writers hold the change
+// sequence lock while they write them.
+//
+// Returns the number of events published, and the error from publish, if any. An event
+// can be published again if the deletion fails, which is why events carry an ID.
+func (s *MysqlStorage) PublishOutbox(
+	ctx context.Context, limit int, publish func(OutboxEvent) error,
+) (n int, err error) {
+	query := `SELECT sequence, event_id, event_type, event_key, event_time, payload
+	FROM outbox
+	ORDER BY sequence ASC
+	LIMIT ?;
+	`
+	// Only storage errors are observed, not those from publish.
+	var storageErr error
+	ctx, end := s.startOperation(ctx, "PublishOutbox", query)
+	defer end(&storageErr)
+
+	var publishErr error
+	storageErr = s.inTx(ctx, func(tx *sql.Tx) error {
+		var lease int
+		if err := tx.QueryRowContext(ctx, "SELECT `id` FROM `outbox_lease` WHERE `id` = 1 FOR UPDATE;").Scan(&lease); err != nil {
+			return fmt.Errorf("failed to take outbox lease: %w", err)
+		}
+		events, err := readEvents(ctx, tx, query, limit)
+		if err != nil {
+			return err
+		}
+
+		var published []any
+		for _, e := range events {
+			if publishErr = publish(e); publishErr != nil {
+				break
+			}
+			published = append(published, e.Sequence)
+		}
+		if len(published) == 0 {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM `outbox` WHERE `sequence` IN "+valuesPlaceholders(1, len(published))+";", published...,
+		); err != nil {
+			return fmt.Errorf("failed to delete published events: %w", err)
+		}
+		n = len(published)
+		return nil
+	})
+	if storageErr != nil {
+		return 0, storageErr
+	}
+	return n, publishErr
+}
+
+// readEvents runs query, which reads up to limit outbox events, within tx.
+func readEvents(ctx context.Context, tx *sql.Tx, query string, limit int) ([]OutboxEvent, error) {
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.Sequence, &e.ID, &e.Type, &e.Key, &e.Time, &e.Payload); err != nil {
+			return nil, fmt.Errorf("failed to parse row into OutboxEvent: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered when iterating over rows: %w", err)
+	}
+	return events, nil
+}
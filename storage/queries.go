@@ -2,46 +2,175 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/celestebrant/library-of-books/books"
 	"github.com/celestebrant/library-of-books/utils"
 )
 
-// CreateBook inserts a new book record into the 'books' table using the provided Book struct.
+// CreateBook inserts a new book record into the 'books' table using the provided Book struct,
+// and records the creation in the change log, and the outbox if enabled, in the same transaction.
 // It takes a context for cancellation and a pointer to a Book struct containing the new book's details.
 // Returns an error if the insert operation fails, including context about the failure.
-func (s *MysqlStorage) CreateBook(ctx context.Context, b *Book) error {
+func (s *MysqlStorage) CreateBook(ctx context.Context, b *Book) (err error) {
 	query := "INSERT INTO `books` (`id`, `creation_time`, `title`, `author`) VALUES (?, ?, ?, ?);"
+	ctx, end := s.startOperation(ctx, "CreateBook", query)
+	defer end(&err)
 
-	if _, err := s.db.ExecContext(ctx, query, b.Id, b.CreationTime, b.Title, b.Author); err != nil {
-		return fmt.Errorf("failed perform SQL query: %w", err)
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, query, b.Id, b.CreationTime, b.Title, b.Author); err != nil {
+			return fmt.Errorf("failed perform SQL query: %w", err)
+		}
+		return s.recordChanges(ctx, tx, ChangeCreated, []*Book{b})
+	})
+}
+
+// MySQL error numbers that insertBooks recovers from.
+const (
+	mysqlErrDuplicateEntry = 1062
+	mysqlErrDeadlock       = 1213
+)
+
+// maxTxAttempts is how many times retryTx runs a transaction that fails with a
+// retryable error.
+const maxTxAttempts = 3
+
+// ErrBookExists is returned by CreateBooks when a book it inserts is created
+// concurrently, and retrying the transaction did not resolve it.
+var ErrBookExists = errors.New("book already exists")
+
+// CreateBooks inserts bs into the 'books' table with a single multi-row INSERT, and
+// records the creations in the change log, and the outbox if enabled, in the same
+// transaction. Books whose ID is already in the table are not inserted, and their IDs
+// are returned. Unless skipExisting is set, no book is inserted if any ID exists. bs
+// must not contain an ID twice.
+func (s *MysqlStorage) CreateBooks(ctx context.Context, bs []*Book, skipExisting bool) (existing []string, err error) {
+	query := "INSERT INTO `books` (`id`, `creation_time`, `title`, `author`) VALUES " + valuesPlaceholders(len(bs), 4) + ";"
+	ctx, end := s.startOperation(ctx, "CreateBooks", query)
+	defer end(&err)
+
+	err = s.retryTx(ctx, func(tx *sql.Tx) error {
+		existing, err = s.insertBooks(ctx, tx, bs, skipExisting)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// insertBooks is CreateBooks within tx. Existing books are found with a plain read, as
+// locking the missing IDs would gap lock them, and concurrent inserts holding the same
+// gap would deadlock. A book inserted concurrently between the read and the INSERT makes
+// the INSERT fail with a duplicate key, which is returned as ErrBookExists for retryTx to
+// retry, when the read finds the book.
+func (s *MysqlStorage) insertBooks(ctx context.Context, tx *sql.Tx, bs []*Book, skipExisting bool) (existing []string, err error) {
+	ids := make([]any, len(bs))
+	for i, b := range bs {
+		ids[i] = b.Id
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM books WHERE id IN "+valuesPlaceholders(1, len(ids))+";", ids...)
+	if err != nil {
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	found := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to parse row into book ID: %w", err)
+		}
+		found[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered when iterating over rows: %w", err)
 	}
 
+	var created []*Book
+	args := make([]any, 0, 4*len(bs))
+	for _, b := range bs {
+		if found[b.Id] {
+			existing = append(existing, b.Id)
+			continue
+		}
+		created = append(created, b)
+		args = append(args, b.Id, b.CreationTime, b.Title, b.Author)
+	}
+	if len(created) == 0 || (len(existing) > 0 && !skipExisting) {
+		return existing, nil
+	}
+	query := "INSERT INTO `books` (`id`, `creation_time`, `title`, `author`) VALUES " + valuesPlaceholders(len(created), 4) + ";"
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		if isMySQLError(err, mysqlErrDuplicateEntry) {
+			return nil, fmt.Errorf("%w: %w", ErrBookExists, err)
+		}
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	return existing, s.recordChanges(ctx, tx, ChangeCreated, created)
+}
+
+// inTx runs fn in a transaction, which is committed if fn returns nil and rolled back
+// otherwise.
+func (s *MysqlStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
+// retryTx is inTx, but runs fn again in a new transaction, up to maxTxAttempts times in
+// all, if the transaction is chosen as a deadlock victim or fn returns ErrBookExists.
+// fn must not have effects outside tx.
+func (s *MysqlStorage) retryTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = s.inTx(ctx, fn)
+		if attempt == maxTxAttempts || !(errors.Is(err, ErrBookExists) || isMySQLError(err, mysqlErrDeadlock)) {
+			return err
+		}
+	}
+}
+
+// isMySQLError reports whether err is a MySQL server error with the given number.
+func isMySQLError(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
+
 func (s *MysqlStorage) ListBooks(
 	ctx context.Context, author, title string, pageSize int64, pageToken string,
-) (*books.ListBooksResponse, error) {
-	// No need to order because
+) (_ *books.ListBooksResponse, err error) {
 	query := `SELECT id, title, author, creation_time
 	FROM books
 	WHERE (author LIKE CONCAT('%', ?, '%') OR ? IS NULL)
 	  AND (title LIKE CONCAT('%', ?, '%') OR ? IS NULL)
-	ORDER BY creation_time ASC
+	ORDER BY creation_time ASC, id ASC -- id breaks ties, so that pages neither repeat nor skip books
 	LIMIT ?  -- page size
 	OFFSET ?; -- skip this number of preceding rows
 	`
+	ctx, end := s.startOperation(ctx, "ListBooks", query)
+	defer end(&err)
+
 	offset, err := utils.Offset(pageToken)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(query, author, author, title, title, pageSize, offset)
+	rows, err := s.db.QueryContext(ctx, query, author, author, title, title, pageSize, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed perform SQL query: %w", err)
 	}
@@ -51,15 +180,10 @@ func (s *MysqlStorage) ListBooks(
 	var fetchedBooks []*books.Book
 	for rows.Next() {
 		var b books.Book
-		var creationTimeDB []uint8
-		if err := rows.Scan(&b.Id, &b.Title, &b.Author, &creationTimeDB); err != nil {
+		var creationTime time.Time
+		if err := rows.Scan(&b.Id, &b.Title, &b.Author, &creationTime); err != nil {
 			return nil, fmt.Errorf("failed to parse row into Book: %w", err)
 		}
-
-		creationTime, err := time.Parse(time.DateTime, string(creationTimeDB))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse creation time from []uint8 to time.Time from ListBooks SQL query: %w", err)
-		}
 		b.CreationTime = timestamppb.New(creationTime)
 
 		fetchedBooks = append(fetchedBooks, &b)
@@ -88,21 +212,18 @@ func (s *MysqlStorage) ListBooks(
 // GetBook retrieves a book from the 'books' table for a given bookID. It returns a
 // populated Book struct on success. It returns sql.ErrNoRows if the book is not found,
 // or another error for any issues during query execution or data parsing.
-func (s *MysqlStorage) GetBook(ctx context.Context, bookID string) (Book, error) {
+func (s *MysqlStorage) GetBook(ctx context.Context, bookID string) (_ Book, err error) {
 	var id, author, title string
-	var creationTimeDB []uint8
+	var creationTime time.Time
 	query := "SELECT id, author, title, creation_time FROM books WHERE id = ? ;"
+	ctx, end := s.startOperation(ctx, "GetBook", query)
+	defer end(&err)
 
 	row := s.db.QueryRowContext(ctx, query, bookID)
-	if err := row.Scan(&id, &author, &title, &creationTimeDB); err != nil {
+	if err := row.Scan(&id, &author, &title, &creationTime); err != nil {
 		return Book{}, err
 	}
 
-	creationTime, err := time.Parse(time.DateTime, string(creationTimeDB))
-	if err != nil {
-		return Book{}, fmt.Errorf("cannot parse creation_time: %w", err)
-	}
-
 	return Book{
 		Id:           id,
 		Author:       author,
@@ -110,3 +231,47 @@ func (s *MysqlStorage) GetBook(ctx context.Context, bookID string) (Book, error)
 		CreationTime: creationTime,
 	}, nil
 }
+
+// GetBooks retrieves the books with the given IDs from the 'books' table with a single
+// query. It returns them keyed by ID, without entries for IDs that are not found.
+func (s *MysqlStorage) GetBooks(ctx context.Context, bookIDs []string) (_ map[string]Book, err error) {
+	query := "SELECT id, author, title, creation_time FROM books WHERE id IN " + valuesPlaceholders(1, len(bookIDs)) + ";"
+	ctx, end := s.startOperation(ctx, "GetBooks", query)
+	defer end(&err)
+
+	args := make([]any, len(bookIDs))
+	for i, id := range bookIDs {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]Book, len(bookIDs))
+	for rows.Next() {
+		var b Book
+		if err := rows.Scan(&b.Id, &b.Author, &b.Title, &b.CreationTime); err != nil {
+			return nil, fmt.Errorf("failed to parse row into Book: %w", err)
+		}
+		found[b.Id] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error encountered when iterating over rows: %w", err)
+	}
+
+	return found, nil
+}
+
+// CountBooks returns the total number of records in the 'books' table.
+func (s *MysqlStorage) CountBooks(ctx context.Context) (n int64, err error) {
+	query := "SELECT COUNT(*) FROM books;"
+	ctx, end := s.startOperation(ctx, "CountBooks", query)
+	defer end(&err)
+
+	if err := s.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed perform SQL query: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies spans created by this package.
+const tracerName = "github.com/celestebrant/library-of-books/storage"
+
+var (
+	sqlComment    = regexp.MustCompile(`--[^\n]*`)
+	sqlWhitespace = regexp.MustCompile(`\s+`)
+)
+
+// startOperation starts a span for a storage operation running query, and returns a
+// context carrying it. The returned function must be deferred with the operation's
+// named error result: it ends the span and notifies the QueryObserver, if any.
+func (s *MysqlStorage) startOperation(
+	ctx context.Context, operation, query string,
+) (context.Context, func(err *error)) {
+	start := time.Now()
+	statement := sanitizeSQL(query)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBName(s.dbName),
+			semconv.DBOperation(sqlOperation(statement)),
+			semconv.DBStatement(statement),
+		),
+	)
+
+	return ctx, func(err *error) {
+		// A missing row is an expected outcome for lookups rather than a failure.
+		if *err != nil && !errors.Is(*err, sql.ErrNoRows) {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+
+		if s.observer != nil {
+			s.observer.ObserveQuery(operation, time.Since(start), *err)
+		}
+	}
+}
+
+// sanitizeSQL strips comments and collapses whitespace in query so that it can be
+// recorded as a span attribute. Queries in this package only take values through
+// placeholders, so the statement itself never contains user data.
+func sanitizeSQL(query string) string {
+	query = sqlComment.ReplaceAllString(query, "")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+}
+
+// sqlOperation returns the leading keyword of statement, such as "SELECT".
+func sqlOperation(statement string) string {
+	operation, _, _ := strings.Cut(statement, " ")
+	return strings.ToUpper(operation)
+}
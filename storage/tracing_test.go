@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	t.Parallel()
+	r := require.New(t)
+
+	query := `SELECT id
+	FROM books
+	LIMIT ?  -- page size
+	OFFSET ?; -- skip rows
+	`
+	r.Equal("SELECT id FROM books LIMIT ? OFFSET ?;", sanitizeSQL(query))
+	r.Equal("SELECT", sqlOperation(sanitizeSQL(query)))
+}
+
+func TestStartOperation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	s := &MysqlStorage{dbName: "library"}
+
+	t.Run("records statement attributes", func(t *testing.T) {
+		r := require.New(t)
+		var err error
+		_, end := s.startOperation(context.Background(), "GetBook", "SELECT id FROM books WHERE id = ? ;")
+		end(&err)
+
+		spans := recorder.Ended()
+		r.NotEmpty(spans)
+		span := spans[len(spans)-1]
+		r.Equal("storage.GetBook", span.Name())
+		r.Contains(span.Attributes(), attribute.String("db.system", "mysql"))
+		r.Contains(span.Attributes(), attribute.String("db.name", "library"))
+		r.Contains(span.Attributes(), attribute.String("db.operation", "SELECT"))
+		r.Contains(span.Attributes(), attribute.String("db.statement", "SELECT id FROM books WHERE id = ? ;"))
+		r.Equal(codes.Unset, span.Status().Code)
+	})
+
+	t.Run("missing row is not an error", func(t *testing.T) {
+		r := require.New(t)
+		err := sql.ErrNoRows
+		_, end := s.startOperation(context.Background(), "GetBook", "SELECT 1;")
+		end(&err)
+
+		spans := recorder.Ended()
+		r.Equal(codes.Unset, spans[len(spans)-1].Status().Code)
+	})
+
+	t.Run("failure sets error status", func(t *testing.T) {
+		r := require.New(t)
+		err := errors.New("duplicate key")
+		_, end := s.startOperation(context.Background(), "CreateBook", "INSERT INTO books VALUES (?);")
+		end(&err)
+
+		spans := recorder.Ended()
+		r.Equal(codes.Error, spans[len(spans)-1].Status().Code)
+	})
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newCreateBookRequest returns a valid CreateBookRequest for a book with id.
+func newCreateBookRequest(id string) *books.CreateBookRequest {
+	return &books.CreateBookRequest{
+		Book:      &books.Book{Id: id, Title: ulid.Make().String(), Author: ulid.Make().String()},
+		RequestId: ulid.Make().String(),
+	}
+}
+
+// TestBatchCreateBooks contains integration tests for the BatchCreateBooks endpoint and db.
+func TestBatchCreateBooks(t *testing.T) {
+	// Prepare set up and tear down of server and client on different port.
+	client, tearDown := setUpServerAndClient("127.0.0.1:8093")
+	defer tearDown()
+
+	t.Run("all requests succeed", func(t *testing.T) {
+		r := require.New(t)
+		ids := []string{ulid.Make().String(), ulid.Make().String(), ulid.Make().String()}
+		req := &books.BatchCreateBooksRequest{}
+		for _, id := range ids {
+			req.Requests = append(req.Requests, newCreateBookRequest(id))
+		}
+
+		res, err := client.BatchCreateBooks(context.Background(), req)
+		r.NoError(err)
+		r.Len(res.Results, len(ids))
+		for i, result := range res.Results {
+			r.Equal(int32(codes.OK), result.Status.Code)
+			r.Equal(ids[i], result.Book.Id)
+			r.Equal(req.Requests[i].Book.Title, result.Book.Title)
+
+			got, err := client.GetBook(context.Background(), &books.GetBookRequest{Id: ids[i]})
+			r.NoError(err)
+			r.Equal(req.Requests[i].Book.Author, got.Book.Author)
+		}
+	})
+
+	t.Run("all or nothing creates nothing if a request is invalid", func(t *testing.T) {
+		r := require.New(t)
+		valid := newCreateBookRequest(ulid.Make().String())
+		invalid := newCreateBookRequest(ulid.Make().String())
+		invalid.Book.Title = ""
+
+		_, err := client.BatchCreateBooks(context.Background(), &books.BatchCreateBooksRequest{
+			Requests: []*books.CreateBookRequest{valid, invalid},
+		})
+		r.Equal(codes.InvalidArgument, status.Code(err))
+		r.Contains(status.Convert(err).Message(), "requests[1]")
+
+		_, err = client.GetBook(context.Background(), &books.GetBookRequest{Id: valid.Book.Id})
+		r.Equal(codes.NotFound, status.Code(err))
+	})
+
+	t.Run("all or nothing creates nothing if a book exists", func(t *testing.T) {
+		r := require.New(t)
+		existing := newCreateBookRequest(ulid.Make().String())
+		_, err := client.CreateBook(context.Background(), existing)
+		r.NoError(err)
+		valid := newCreateBookRequest(ulid.Make().String())
+
+		_, err = client.BatchCreateBooks(context.Background(), &books.BatchCreateBooksRequest{
+			Requests: []*books.CreateBookRequest{valid, newCreateBookRequest(existing.Book.Id)},
+		})
+		r.Equal(codes.AlreadyExists, status.Code(err))
+
+		_, err = client.GetBook(context.Background(), &books.GetBookRequest{Id: valid.Book.Id})
+		r.Equal(codes.NotFound, status.Code(err))
+	})
+
+	t.Run("partial success reports each request", func(t *testing.T) {
+		r := require.New(t)
+		existing := newCreateBookRequest(ulid.Make().String())
+		_, err := client.CreateBook(context.Background(), existing)
+		r.NoError(err)
+
+		valid := newCreateBookRequest(ulid.Make().String())
+		invalid := newCreateBookRequest(ulid.Make().String())
+		invalid.Book.Author = ""
+		res, err := client.BatchCreateBooks(context.Background(), &books.BatchCreateBooksRequest{
+			Requests: []*books.CreateBookRequest{
+				valid,
+				invalid,
+				newCreateBookRequest(existing.Book.Id),
+				newCreateBookRequest(valid.Book.Id),
+			},
+			AllowPartialSuccess: true,
+		})
+		r.NoError(err)
+		r.Len(res.Results, 4)
+		r.Equal(int32(codes.OK), res.Results[0].Status.Code)
+		r.Equal(valid.Book.Id, res.Results[0].Book.Id)
+		r.Equal(int32(codes.InvalidArgument), res.Results[1].Status.Code)
+		r.Equal(int32(codes.AlreadyExists), res.Results[2].Status.Code)
+		r.Equal(int32(codes.AlreadyExists), res.Results[3].Status.Code)
+
+		_, err = client.GetBook(context.Background(), &books.GetBookRequest{Id: valid.Book.Id})
+		r.NoError(err)
+	})
+
+	t.Run("IDs differing only in case are different books", func(t *testing.T) {
+		r := require.New(t)
+		existing := newCreateBookRequest(ulid.Make().String())
+		_, err := client.CreateBook(context.Background(), existing)
+		r.NoError(err)
+
+		res, err := client.BatchCreateBooks(context.Background(), &books.BatchCreateBooksRequest{
+			Requests: []*books.CreateBookRequest{newCreateBookRequest(strings.ToLower(existing.Book.Id))},
+		})
+		r.NoError(err)
+		r.Equal(int32(codes.OK), res.Results[0].Status.Code)
+		r.Equal(strings.ToLower(existing.Book.Id), res.Results[0].Book.Id)
+	})
+
+	t.Run("concurrent batches all succeed", func(t *testing.T) {
+		r := require.New(t)
+		// ULIDs made in sequence all fall after the existing IDs, in the same index gap.
+		const batches, size = 8, 20
+		reqs := make([]*books.BatchCreateBooksRequest, batches)
+		for i := range reqs {
+			reqs[i] = &books.BatchCreateBooksRequest{}
+			for j := 0; j < size; j++ {
+				reqs[i].Requests = append(reqs[i].Requests, newCreateBookRequest(ulid.Make().String()))
+			}
+		}
+
+		errs := make([]error, batches)
+		var wg sync.WaitGroup
+		for i, req := range reqs {
+			wg.Add(1)
+			go func(i int, req *books.BatchCreateBooksRequest) {
+				defer wg.Done()
+				_, errs[i] = client.BatchCreateBooks(context.Background(), req)
+			}(i, req)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			r.NoError(err)
+		}
+	})
+
+	t.Run("concurrent batches with the same IDs create each book once", func(t *testing.T) {
+		r := require.New(t)
+		ids := make([]string, 20)
+		for i := range ids {
+			ids[i] = ulid.Make().String()
+		}
+
+		const batches = 8
+		results := make([]*books.BatchCreateBooksResponse, batches)
+		errs := make([]error, batches)
+		var wg sync.WaitGroup
+		for i := 0; i < batches; i++ {
+			req := &books.BatchCreateBooksRequest{AllowPartialSuccess: true}
+			for _, id := range ids {
+				req.Requests = append(req.Requests, newCreateBookRequest(id))
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = client.BatchCreateBooks(context.Background(), req)
+			}(i)
+		}
+		wg.Wait()
+
+		created := make(map[string]int)
+		for i, res := range results {
+			r.NoError(errs[i])
+			for _, result := range res.Results {
+				if result.Status.Code == int32(codes.OK) {
+					created[result.Book.Id]++
+					continue
+				}
+				r.Equal(int32(codes.AlreadyExists), result.Status.Code)
+			}
+		}
+		for _, id := range ids {
+			r.Equal(1, created[id], "book %s", id)
+		}
+	})
+
+	t.Run("empty batch is invalid", func(t *testing.T) {
+		r := require.New(t)
+		_, err := client.BatchCreateBooks(context.Background(), &books.BatchCreateBooksRequest{})
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+}
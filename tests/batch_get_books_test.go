@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestBatchGetBooks contains integration tests for the BatchGetBooks endpoint and db.
+func TestBatchGetBooks(t *testing.T) {
+	// Prepare set up and tear down of server and client on different port.
+	client, tearDown := setUpServerAndClient("127.0.0.1:8094")
+	defer tearDown()
+
+	t.Run("books are returned in request order and missing IDs are listed", func(t *testing.T) {
+		r := require.New(t)
+		var ids []string
+		for i := 0; i < 3; i++ {
+			req := newCreateBookRequest(ulid.Make().String())
+			_, err := client.CreateBook(context.Background(), req)
+			r.NoError(err)
+			ids = append(ids, req.Book.Id)
+		}
+		missing := ulid.Make().String()
+
+		res, err := client.BatchGetBooks(context.Background(), &books.BatchGetBooksRequest{
+			Ids: []string{ids[2], missing, ids[0], ids[1]},
+		})
+		r.NoError(err)
+		r.Len(res.Books, 3)
+		r.Equal(ids[2], res.Books[0].Id)
+		r.Equal(ids[0], res.Books[1].Id)
+		r.Equal(ids[1], res.Books[2].Id)
+		r.Equal([]string{missing}, res.MissingIds)
+	})
+
+	t.Run("IDs match exactly, as in GetBook", func(t *testing.T) {
+		r := require.New(t)
+		req := newCreateBookRequest(ulid.Make().String())
+		_, err := client.CreateBook(context.Background(), req)
+		r.NoError(err)
+		lower := strings.ToLower(req.Book.Id)
+
+		res, err := client.BatchGetBooks(context.Background(), &books.BatchGetBooksRequest{Ids: []string{lower}})
+		r.NoError(err)
+		r.Empty(res.Books)
+		r.Equal([]string{lower}, res.MissingIds)
+	})
+
+	t.Run("no IDs is invalid", func(t *testing.T) {
+		r := require.New(t)
+		_, err := client.BatchGetBooks(context.Background(), &books.BatchGetBooksRequest{})
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+}
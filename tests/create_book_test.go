@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
 	"github.com/celestebrant/library-of-books/internal/services/booksclient"
 	"github.com/celestebrant/library-of-books/internal/services/booksservice"
 	"github.com/celestebrant/library-of-books/storage"
@@ -23,10 +24,14 @@ import (
 // setUpServerAndClient sets up the server and client. Returns the client and
 // tear-down actvities which should be deferred.
 func setUpServerAndClient(address string) (books.BooksClient, func()) {
+	conf := config.Default()
+	conf.Server.Address = address
+	conf.Client.Address = address
+
 	var wg sync.WaitGroup
 	wg.Add(1)
-	server, lis := booksservice.MustNewBooksServer(address, &wg)
-	client, conn := booksclient.MustNewBooksClient(address)
+	server, lis := booksservice.MustNewBooksServer(conf, &wg)
+	client, conn := booksclient.MustNewBooksClient(conf.Client)
 
 	return client, func() {
 		booksservice.StopBooksServer(server, lis, &wg)
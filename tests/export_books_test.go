@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/transfer"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TestExportBooks contains integration tests for exporting books through ListBooks and
+// the db.
+func TestExportBooks(t *testing.T) {
+	// Prepare set up and tear down of server and client on different port.
+	client, tearDown := setUpServerAndClient("127.0.0.1:8096")
+	defer tearDown()
+
+	t.Run("books created at the same time are each exported once", func(t *testing.T) {
+		r := require.New(t)
+		prefix := ulid.Make().String()
+		creationTime := timestamppb.New(time.Now().UTC().Truncate(time.Second))
+
+		// Enough books to span several export pages, all with one creation time.
+		req := &books.BatchCreateBooksRequest{}
+		for i := 0; i < 150; i++ {
+			create := newCreateBookRequest(fmt.Sprintf("%s_%d", prefix, i))
+			create.Book.CreationTime = creationTime
+			req.Requests = append(req.Requests, create)
+		}
+		_, err := client.BatchCreateBooks(context.Background(), req)
+		r.NoError(err)
+
+		var buf bytes.Buffer
+		w, err := transfer.NewWriter(transfer.FormatJSONL, &buf)
+		r.NoError(err)
+		_, err = transfer.Export(context.Background(), client, w, 5*time.Second)
+		r.NoError(err)
+
+		exported := make(map[string]int)
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var b struct {
+				ID string `json:"id"`
+			}
+			r.NoError(json.Unmarshal([]byte(line), &b))
+			if strings.HasPrefix(b.ID, prefix) {
+				exported[b.ID]++
+			}
+		}
+		r.Len(exported, len(req.Requests))
+		for id, n := range exported {
+			r.Equal(1, n, "book %s exported %d times", id, n)
+		}
+	})
+}
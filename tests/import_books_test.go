@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// importBooks streams reqs to ImportBooks and returns every response.
+func importBooks(t *testing.T, client books.BooksClient, reqs []*books.ImportBooksRequest) []*books.ImportBooksResponse {
+	t.Helper()
+	r := require.New(t)
+	stream, err := client.ImportBooks(context.Background())
+	r.NoError(err)
+	for _, req := range reqs {
+		r.NoError(stream.Send(req))
+	}
+	r.NoError(stream.CloseSend())
+
+	var responses []*books.ImportBooksResponse
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return responses
+		}
+		r.NoError(err)
+		responses = append(responses, res)
+	}
+}
+
+// newImportRecords returns requests for importID with a record for each book ID.
+func newImportRecords(importID string, ids ...string) []*books.ImportBooksRequest {
+	reqs := make([]*books.ImportBooksRequest, len(ids))
+	for i, id := range ids {
+		reqs[i] = &books.ImportBooksRequest{
+			Offset: int64(i),
+			Book:   newCreateBookRequest(id).Book,
+		}
+	}
+	reqs[0].ImportId = importID
+	return reqs
+}
+
+// TestImportBooks contains integration tests for the ImportBooks endpoint and db.
+func TestImportBooks(t *testing.T) {
+	// Prepare set up and tear down of server and client on different port.
+	client, tearDown := setUpServerAndClient("127.0.0.1:8095")
+	defer tearDown()
+
+	t.Run("imports records and reports a summary", func(t *testing.T) {
+		r := require.New(t)
+		existing := newCreateBookRequest(ulid.Make().String())
+		_, err := client.CreateBook(context.Background(), existing)
+		r.NoError(err)
+
+		ids := []string{ulid.Make().String(), existing.Book.Id, ulid.Make().String()}
+		reqs := newImportRecords(ulid.Make().String(), ids...)
+		reqs[2].Book.Author = ""
+
+		responses := importBooks(t, client, reqs)
+		summary := responses[len(responses)-1]
+		r.True(summary.Done)
+		r.EqualValues(3, summary.Committed)
+		r.EqualValues(1, summary.Inserted)
+		r.EqualValues(1, summary.SkippedDuplicates)
+		r.EqualValues(1, summary.Rejected)
+		r.Len(summary.Rejections, 1)
+		r.EqualValues(2, summary.Rejections[0].Offset)
+
+		got, err := client.GetBook(context.Background(), &books.GetBookRequest{Id: ids[0]})
+		r.NoError(err)
+		r.Equal(reqs[0].Book.Title, got.Book.Title)
+	})
+
+	t.Run("resumed import skips committed records", func(t *testing.T) {
+		r := require.New(t)
+		importID := ulid.Make().String()
+		ids := []string{ulid.Make().String(), ulid.Make().String(), ulid.Make().String()}
+		reqs := newImportRecords(importID, ids...)
+		importBooks(t, client, reqs[:2])
+
+		responses := importBooks(t, client, reqs)
+		r.EqualValues(2, responses[0].Committed)
+		summary := responses[len(responses)-1]
+		r.EqualValues(3, summary.Committed)
+		r.EqualValues(3, summary.Inserted)
+		r.Zero(summary.SkippedDuplicates)
+	})
+
+	t.Run("missing import ID is invalid", func(t *testing.T) {
+		r := require.New(t)
+		stream, err := client.ImportBooks(context.Background())
+		r.NoError(err)
+		r.NoError(stream.Send(&books.ImportBooksRequest{}))
+		r.NoError(stream.CloseSend())
+		_, err = stream.Recv()
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/config"
+	"github.com/celestebrant/library-of-books/internal/outbox"
+	"github.com/celestebrant/library-of-books/internal/services/booksclient"
+	"github.com/celestebrant/library-of-books/internal/services/booksservice"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents returns the events published to the outbox file at path.
+func readEvents(t *testing.T, path string) []outbox.Event {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []outbox.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e outbox.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+// TestOutbox contains integration tests for publishing book change events through the
+// outbox.
+func TestOutbox(t *testing.T) {
+	conf := config.Default()
+	conf.Server.Address = "127.0.0.1:8092"
+	conf.Client.Address = conf.Server.Address
+	conf.Server.Outbox.Enabled = true
+	conf.Server.Outbox.FilePath = filepath.Join(t.TempDir(), "events.jsonl")
+	conf.Server.Outbox.PollInterval = 10 * time.Millisecond
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	server, lis := booksservice.MustNewBooksServer(conf, &wg)
+	client, conn := booksclient.MustNewBooksClient(conf.Client)
+	defer func() {
+		booksservice.StopBooksServer(server, lis, &wg)
+		conn.Close()
+	}()
+
+	t.Run("created book is published", func(t *testing.T) {
+		r := require.New(t)
+		id := ulid.Make().String()
+		_, err := client.CreateBook(context.Background(), &books.CreateBookRequest{
+			Book:      &books.Book{Id: id, Title: id, Author: id},
+			RequestId: ulid.Make().String(),
+		})
+		r.NoError(err)
+
+		var event outbox.Event
+		r.Eventually(func() bool {
+			for _, e := range readEvents(t, conf.Server.Outbox.FilePath) {
+				if e.Key == id {
+					event = e
+					return true
+				}
+			}
+			return false
+		}, 5*time.Second, 10*time.Millisecond)
+
+		r.Equal("book.created", event.Type)
+		r.NotEmpty(event.ID)
+		var book struct {
+			Title string `json:"title"`
+		}
+		r.NoError(json.Unmarshal(event.Payload, &book))
+		r.Equal(id, book.Title)
+	})
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/celestebrant/library-of-books/books"
+	"github.com/celestebrant/library-of-books/internal/services/booksservice"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestWatchBooks contains integration tests for the WatchBooks service method and the
+// change log.
+func TestWatchBooks(t *testing.T) {
+	// Prepare set up and tear down of server and client on different port.
+	client, tearDown := setUpServerAndClient("127.0.0.1:8091")
+	defer tearDown()
+
+	// createBook creates a book and returns its ID.
+	createBook := func(r *require.Assertions) string {
+		id := ulid.Make().String()
+		_, err := client.CreateBook(context.Background(), &books.CreateBookRequest{
+			Book:      &books.Book{Id: id, Title: id, Author: id},
+			RequestId: ulid.Make().String(),
+		})
+		r.NoError(err)
+		return id
+	}
+
+	t.Run("created book is streamed and watch resumes from its cursor", func(t *testing.T) {
+		r := require.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		stream, err := client.WatchBooks(ctx, &books.WatchBooksRequest{})
+		r.NoError(err)
+		header, err := stream.Header()
+		r.NoError(err)
+		r.Len(header.Get(booksservice.WatchCursorHeader), 1)
+
+		first := createBook(r)
+		res, err := stream.Recv()
+		r.NoError(err)
+		r.Equal(books.WatchBooksResponse_CREATED, res.Type)
+		r.Equal(first, res.Book.Id)
+		r.Equal(first, res.Book.Title)
+		r.NotEmpty(res.Cursor)
+
+		// A change made while disconnected is delivered after resuming.
+		second := createBook(r)
+		resumed, err := client.WatchBooks(ctx, &books.WatchBooksRequest{Cursor: res.Cursor})
+		r.NoError(err)
+		res, err = resumed.Recv()
+		r.NoError(err)
+		r.Equal(second, res.Book.Id)
+	})
+
+	t.Run("malformed cursor is invalid", func(t *testing.T) {
+		r := require.New(t)
+		stream, err := client.WatchBooks(context.Background(), &books.WatchBooksRequest{Cursor: "!"})
+		r.NoError(err)
+		_, err = stream.Recv()
+		r.Equal(codes.InvalidArgument, status.Code(err))
+	})
+}